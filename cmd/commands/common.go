@@ -1,13 +1,103 @@
 package commands
 
+import (
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/dbason/opni-supportagent/pkg/errors"
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"github.com/dbason/opni-supportagent/pkg/output"
+	"github.com/spf13/cobra"
+)
+
 var (
 	password string
 )
 
-type Distribution string
+// promptForPassword reads the Opensearch password from the flags, asking
+// for it if it wasn't set.
+func promptForPassword(cmd *cobra.Command) error {
+	var err error
+	password, err = cmd.Flags().GetString("password")
+	if err != nil {
+		return err
+	}
 
-const (
-	RKE  Distribution = "rke"
-	RKE2 Distribution = "rke2"
-	K3S  Distribution = "k3s"
-)
+	if password != "" {
+		return nil
+	}
+
+	return survey.AskOne(
+		&survey.Password{
+			Message: "please enter the opensearch password",
+		},
+		&password,
+		survey.WithValidator(survey.Required),
+	)
+}
+
+// addIndexerFlags adds the flags controlling how logs are indexed into
+// Opensearch.
+func addIndexerFlags(command *cobra.Command) {
+	command.Flags().Bool("skip-existing", false, "leave logs that have already been published as they are instead of replacing them")
+	command.Flags().Int("indexer-workers", 0, "number of bulk requests sent to Opensearch at the same time, defaults to the number of CPUs")
+	command.Flags().Int("flush-bytes", 0, "size in bytes of each bulk request sent to Opensearch, defaults to 5MB")
+	command.Flags().Bool("log-requests", false, "log every request sent to Opensearch")
+}
+
+func getIndexerConfig(cmd *cobra.Command) (output.OpensearchSinkConfig, error) {
+	skipExisting, err := cmd.Flags().GetBool("skip-existing")
+	if err != nil {
+		return output.OpensearchSinkConfig{}, err
+	}
+	numWorkers, err := cmd.Flags().GetInt("indexer-workers")
+	if err != nil {
+		return output.OpensearchSinkConfig{}, err
+	}
+	flushBytes, err := cmd.Flags().GetInt("flush-bytes")
+	if err != nil {
+		return output.OpensearchSinkConfig{}, err
+	}
+	logRequests, err := cmd.Flags().GetBool("log-requests")
+	if err != nil {
+		return output.OpensearchSinkConfig{}, err
+	}
+	return output.OpensearchSinkConfig{
+		SkipExisting: skipExisting,
+		NumWorkers:   numWorkers,
+		FlushBytes:   flushBytes,
+		LogRequests:  logRequests,
+	}, nil
+}
+
+// addFailureFlags adds the flags controlling how logs that fail to publish
+// are handled.
+func addFailureFlags(command *cobra.Command) {
+	command.Flags().Float64("failure-threshold", 0, "fraction of logs, between 0 and 1, that may fail to publish before the command exits with an error")
+}
+
+// deadLetter writes the logs the sink fails to store to path.  Nil is
+// returned if the sink doesn't report failed logs.
+func deadLetter(sink input.Sink, path string) *output.DeadLetterFile {
+	failureReportingSink, ok := sink.(input.FailureReportingSink)
+	if !ok {
+		return nil
+	}
+	failed := output.NewDeadLetterFile(path)
+	failureReportingSink.OnFailed(failed.Add)
+	return failed
+}
+
+// checkFailures returns an error if more logs failed than the threshold
+// allows.
+func checkFailures(cmd *cobra.Command, stats input.SinkStats) error {
+	threshold, err := cmd.Flags().GetFloat64("failure-threshold")
+	if err != nil {
+		return err
+	}
+	if stats.NumFailed == 0 {
+		return nil
+	}
+	if stats.NumAdded == 0 || float64(stats.NumFailed)/float64(stats.NumAdded) > threshold {
+		return errors.ErrTooManyFailuresWithCount(stats.NumFailed, stats.NumAdded)
+	}
+	return nil
+}
@@ -7,7 +7,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/AlecAivazis/survey/v2"
 	"github.com/dbason/opni-supportagent/pkg/errors"
 	"github.com/dbason/opni-supportagent/pkg/util"
 	"github.com/opensearch-project/opensearch-go"
@@ -88,21 +87,5 @@ func deleteLogs(cmd *cobra.Command, args []string) error {
 }
 
 func getDeletePassword(cmd *cobra.Command, args []string) error {
-	var err error
-	password, err = cmd.Flags().GetString("password")
-	if err != nil {
-		return err
-	}
-
-	if password != "" {
-		return nil
-	}
-
-	return survey.AskOne(
-		&survey.Password{
-			Message: "please enter the opensearch password",
-		},
-		&password,
-		survey.WithValidator(survey.Required),
-	)
+	return promptForPassword(cmd)
 }
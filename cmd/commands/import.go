@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"github.com/dbason/opni-supportagent/pkg/output"
+	"github.com/dbason/opni-supportagent/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+func BuildImportCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:     "import file",
+		Short:   "import logs exported with publish --output file:// into Opensearch",
+		PreRunE: getImportPassword,
+		RunE:    importLogs,
+	}
+
+	addIndexerFlags(command)
+
+	return command
+}
+
+func importLogs(cmd *cobra.Command, args []string) (err error) {
+	caseNumber, err := cmd.Flags().GetString("case-number")
+	if err != nil {
+		return err
+	}
+	endpoint, err := cmd.Flags().GetString("endpoint")
+	if err != nil {
+		return err
+	}
+	username, err := cmd.Flags().GetString("username")
+	if err != nil {
+		return err
+	}
+
+	indexerConfig, err := getIndexerConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	sink, err := output.NewOpensearchSink(endpoint, username, password, indexerConfig)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Flush the logs that were read even if the command was interrupted
+		if closeErr := sink.Close(context.Background()); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		stats := sink.Stats()
+		util.Log.Infof("case %s logs: %d flushed, %d skipped, %d failed", caseNumber, stats.NumFlushed, stats.NumSkipped, stats.NumFailed)
+	}()
+
+	imported, err := input.ImportNDJSON(cmd.Context(), args[0], caseNumber, sink)
+	util.Log.Infof("read %d logs from %s", imported, args[0])
+	if cmd.Context().Err() != nil {
+		return errors.ErrInterrupted
+	}
+	return err
+}
+
+func getImportPassword(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.ErrInvalidArgumentNumber(1)
+	}
+	return promptForPassword(cmd)
+}
@@ -1,25 +1,48 @@
 package commands
 
 import (
-	"github.com/AlecAivazis/survey/v2"
+	"context"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/bundle"
 	"github.com/dbason/opni-supportagent/pkg/errors"
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"github.com/dbason/opni-supportagent/pkg/output"
 	"github.com/dbason/opni-supportagent/pkg/publish"
+	"github.com/dbason/opni-supportagent/pkg/util"
 	"github.com/spf13/cobra"
 )
 
 func BuildPublishCommand() *cobra.Command {
 	command := &cobra.Command{
-		Use:     "publish cluster-type",
+		Use:     "publish [cluster-type]",
 		Short:   "publish support bundle to remote opni cluster",
 		PreRunE: getPassword,
 		RunE:    publishLogs,
 	}
 
+	command.Flags().String("layout-file", "", "bundle layout to use instead of the built in layout for the cluster type")
+	command.Flags().String("bundle", "", "support bundle to publish, either a .tar.gz, .tgz or .zip archive or a directory")
+	command.Flags().String("bundle-dir", ".", "directory containing the unzipped support bundle")
+	command.Flags().String("output", "", "where to publish the logs, defaults to the Opensearch endpoint.  file://<path> writes the logs to an NDJSON file, gzipped if the path ends in .gz, opni+<url> posts them to an Opni payload receiver and loki+<url> pushes them to Loki")
+	command.Flags().String("temp-dir", "", "directory tarball bundles are decompressed into while they are published, defaults to $TMPDIR or /tmp")
+	command.Flags().Bool("multi-node", false, "the bundle holds a bundle for each node, node names are taken from the node bundles")
+	command.Flags().Bool("resume", false, "carry on from where an interrupted publish of the bundle stopped")
+	command.Flags().Int("workers", 0, "number of log files read at the same time, defaults to the number of CPUs")
+	command.Flags().String("unparsed", string(input.UnparsedAttach), "what to do with lines whose timestamp can't be parsed, one of skip, attach to add them to the previous log, or index to publish them with the last timestamp read and the unparsed field set")
+	command.Flags().Int("max-line-size", input.DefaultMaxLineSize, "longest line in bytes read from the bundle, longer lines are truncated and marked with truncated: true")
+	command.Flags().String("timezone", "", "IANA timezone of the node, e.g. America/Chicago, for logs without a timezone, defaults to the timezone in the bundle")
+	command.Flags().Bool("progress", true, "show how far through the bundle the publish is, redrawn in place on a terminal and logged every 10 seconds otherwise")
+	command.Flags().String("failed-logs", "", "NDJSON file the logs that fail to publish are written to, defaults to a file next to the checkpoint")
+	addIndexerFlags(command)
+	addFailureFlags(command)
+
 	return command
 }
 
-func publishLogs(cmd *cobra.Command, args []string) error {
-	var err error
+func publishLogs(cmd *cobra.Command, args []string) (err error) {
 	caseNumber, err := cmd.Flags().GetString("case-number")
 	if err != nil {
 		return err
@@ -36,60 +59,344 @@ func publishLogs(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	layoutFile, err := cmd.Flags().GetString("layout-file")
+	if err != nil {
+		return err
+	}
+
+	bundlePath, err := cmd.Flags().GetString("bundle")
+	if err != nil {
+		return err
+	}
+	if bundlePath == "" {
+		bundlePath, err = cmd.Flags().GetString("bundle-dir")
+		if err != nil {
+			return err
+		}
+	}
+	tempDir, err := cmd.Flags().GetString("temp-dir")
+	if err != nil {
+		return err
+	}
+	multiNode, err := cmd.Flags().GetBool("multi-node")
+	if err != nil {
+		return err
+	}
+	outputURL, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	indexerConfig, err := getIndexerConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	resume, err := cmd.Flags().GetBool("resume")
+	if err != nil {
+		return err
+	}
+	workers, err := cmd.Flags().GetInt("workers")
+	if err != nil {
+		return err
+	}
+	unparsed, err := cmd.Flags().GetString("unparsed")
+	if err != nil {
+		return err
+	}
+	unparsedPolicy := input.UnparsedPolicy(unparsed)
+	if !unparsedPolicy.Valid() {
+		return errors.ErrInvalidPolicy
+	}
+	maxLineSize, err := cmd.Flags().GetInt("max-line-size")
+	if err != nil {
+		return err
+	}
+	timezone, err := cmd.Flags().GetString("timezone")
+	if err != nil {
+		return err
+	}
+	var location *time.Location
+	if timezone != "" {
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			return errors.ErrInvalidTimezoneWithName(timezone)
+		}
+	}
+	showProgress, err := cmd.Flags().GetBool("progress")
+	if err != nil {
+		return err
+	}
+	failedLogsPath, err := cmd.Flags().GetString("failed-logs")
+	if err != nil {
+		return err
+	}
+	if failedLogsPath == "" {
+		failedLogsPath = bundle.FailedLogsPath(bundlePath)
+	}
 
-	switch Distribution(args[0]) {
-	case RKE:
-		err = publish.ShipRKEControlPlane(
-			cmd.Context(),
-			endpoint,
-			caseNumber,
-			nodeName,
-			username,
-			password,
-		)
-	case K3S:
-		err = publish.ShipK3SControlPlane(
-			cmd.Context(),
-			endpoint,
-			caseNumber,
-			nodeName,
-			username,
-			password,
-		)
-	case RKE2:
-		err = publish.ShipRKE2ControlPlane(
-			cmd.Context(),
-			endpoint,
-			caseNumber,
-			nodeName,
-			username,
-			password,
-		)
-	default:
-		err = errors.ErrInvalidDist
+	sink, err := output.NewSink(outputURL, endpoint, username, password, indexerConfig)
+	if err != nil {
+		return err
+	}
+
+	var checkpoint *input.Checkpoint
+	if acknowledgingSink, ok := sink.(input.AcknowledgingSink); ok {
+		checkpoint, err = input.LoadCheckpoint(bundle.CheckpointPath(bundlePath), caseNumber, resume)
+		if err != nil {
+			return err
+		}
+		acknowledgingSink.OnStored(checkpoint.Acknowledge)
+	} else if resume {
+		util.Log.Warn("the output doesn't support resuming, publishing all logs")
 	}
+
+	failed := deadLetter(sink, failedLogsPath)
+
+	defer func() {
+		// Flush the logs that were read even if the command was interrupted
+		if closeErr := sink.Close(context.Background()); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		stats := sink.Stats()
+		if failed != nil {
+			if closeErr := failed.Close(context.Background()); closeErr != nil {
+				util.Log.Warnf("unable to write failed logs: %s", closeErr)
+			}
+			failed.Report()
+			if failed.Total() > 0 {
+				util.Log.Warnf("failed logs were written to %s, send them again with retry-failed", failed.Path())
+			}
+			// Logs left from an earlier publish have been published again
+			if err == nil && failed.Total() == 0 {
+				if removeErr := os.Remove(failed.Path()); removeErr != nil && !os.IsNotExist(removeErr) {
+					util.Log.Warnf("unable to remove failed logs: %s", removeErr)
+				}
+			}
+		}
+		if err == nil {
+			err = checkFailures(cmd, stats)
+		}
+
+		if checkpoint == nil {
+			return
+		}
+		// Keep the checkpoint until every log has been stored
+		if err == nil && stats.NumFailed == 0 {
+			removed, removeErr := checkpoint.Remove()
+			if removeErr != nil {
+				util.Log.Warnf("unable to remove checkpoint: %s", removeErr)
+				return
+			}
+			if removed {
+				return
+			}
+		}
+		if saveErr := checkpoint.Save(); saveErr != nil {
+			util.Log.Warnf("unable to save checkpoint: %s", saveErr)
+			return
+		}
+		if cmd.Context().Err() != nil {
+			util.Log.Warn("run publish again with --resume to carry on from where it stopped")
+		}
+	}()
+
+	options := publishOptions{
+		sink:        sink,
+		caseNumber:  caseNumber,
+		layoutFile:  layoutFile,
+		checkpoint:  checkpoint,
+		workers:     workers,
+		progress:    showProgress,
+		unparsed:    unparsedPolicy,
+		maxLineSize: maxLineSize,
+		timezone:    location,
+	}
+
+	if multiNode {
+		return publishNodes(cmd.Context(), bundlePath, tempDir, args, options)
+	}
+
+	supportBundle, err := bundle.Open(bundlePath, tempDir)
+	if err != nil {
+		return err
+	}
+	defer supportBundle.Close()
+
+	_, err = publishBundle(cmd.Context(), supportBundle, nodeName, args, options)
 	return err
 }
 
-func getPassword(cmd *cobra.Command, args []string) error {
-	var err error
-	if len(args) != 1 {
-		return errors.ErrInvalidArgumentNumber(1)
+type publishOptions struct {
+	sink        input.Sink
+	caseNumber  string
+	layoutFile  string
+	checkpoint  *input.Checkpoint
+	workers     int
+	progress    bool
+	unparsed    input.UnparsedPolicy
+	maxLineSize int
+	timezone    *time.Location
+}
+
+func publishBundle(
+	ctx context.Context,
+	supportBundle fs.FS,
+	nodeName string,
+	args []string,
+	options publishOptions,
+) (*publish.Summary, error) {
+	distribution, err := resolveDistribution(supportBundle, args)
+	if err != nil {
+		return nil, err
 	}
-	password, err = cmd.Flags().GetString("password")
+
+	layout, err := publish.LoadLayout(distribution, options.layoutFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return publish.ShipControlPlane(
+		ctx,
+		supportBundle,
+		layout,
+		options.sink,
+		publish.ShipConfig{
+			ClusterName:    options.caseNumber,
+			NodeName:       nodeName,
+			Checkpoint:     options.checkpoint,
+			Workers:        options.workers,
+			Progress:       options.progress,
+			UnparsedPolicy: options.unparsed,
+			MaxLineSize:    options.maxLineSize,
+			Timezone:       options.timezone,
+		},
+	)
+}
+
+// publishNodes publishes the bundle of every node in the collection under
+// the same case, naming each node from its bundle.  Each node is opened only
+// while it is published, so only one node of a tarball is decompressed at a
+// time.
+func publishNodes(ctx context.Context, collectionPath string, tempDir string, args []string, options publishOptions) error {
+	collection, err := bundle.OpenCollection(collectionPath, tempDir)
 	if err != nil {
 		return err
 	}
+	defer collection.Close()
+
+	if len(collection.Nodes) == 0 {
+		return errors.ErrNoNodeBundles
+	}
+
+	summaries := make([]*publish.Summary, len(collection.Nodes))
+	nodeErrors := make([]error, len(collection.Nodes))
+	for i, node := range collection.Nodes {
+		if ctx.Err() != nil {
+			nodeErrors[i] = errors.ErrInterrupted
+			continue
+		}
+		summaries[i], nodeErrors[i] = publishNode(ctx, node, args, options)
+		if nodeErrors[i] != nil {
+			util.Log.Errorf("failed to publish node %s: %s", node.Name, nodeErrors[i])
+		}
+	}
+
+	var failed int
+	util.Log.Info("node summary:")
+	for i, node := range collection.Nodes {
+		summary := summaries[i]
+		switch {
+		case nodeErrors[i] != nil:
+			failed++
+			util.Log.Infof("  %s: failed: %s", node.Name, nodeErrors[i])
+		case summary.Start.IsZero():
+			util.Log.Infof("  %s: %d components published, %d skipped, %d logs flushed, %d already published, %d failed",
+				node.Name,
+				len(summary.Published),
+				len(summary.Skipped),
+				summary.Flushed,
+				summary.Existing,
+				summary.Failed,
+			)
+		default:
+			util.Log.Infof("  %s: %d components published, %d skipped, %d logs flushed, %d already published, %d failed, logs from %s to %s",
+				node.Name,
+				len(summary.Published),
+				len(summary.Skipped),
+				summary.Flushed,
+				summary.Existing,
+				summary.Failed,
+				summary.Start.Format(time.RFC3339),
+				summary.End.Format(time.RFC3339),
+			)
+		}
+	}
 
-	if password != "" {
+	if ctx.Err() != nil {
+		return errors.ErrInterrupted
+	}
+	if failed > 0 {
+		return errors.ErrNodesFailed(failed, len(collection.Nodes))
+	}
+	return nil
+}
+
+// publishNode opens the bundle of the node, publishes it and closes it again.
+func publishNode(ctx context.Context, node *bundle.Node, args []string, options publishOptions) (*publish.Summary, error) {
+	nodeBundle, err := node.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer nodeBundle.Close()
+
+	util.Log.Infof("publishing logs for node %s", node.Name)
+	return publishBundle(ctx, nodeBundle, node.Name, args, options)
+}
+
+// resolveDistribution checks the cluster type argument against the bundle
+// contents, or detects it from the bundle if it wasn't specified.
+func resolveDistribution(bundle fs.FS, args []string) (publish.Distribution, error) {
+	detected, confidence, detectErr := publish.DetectDistribution(bundle)
+	if detectErr == nil {
+		util.Log.Infof("bundle looks like %s (confidence %.2f)", detected, confidence)
+	}
+
+	if len(args) == 0 {
+		if detectErr != nil {
+			return "", detectErr
+		}
+		if confidence < publish.MinDetectionConfidence {
+			return "", errors.ErrUnknownDist
+		}
+		return detected, nil
+	}
+
+	distribution := publish.Distribution(args[0])
+	if !distribution.Valid() {
+		return "", errors.ErrInvalidDist
+	}
+	if detectErr != nil {
+		util.Log.Warnf("unable to confirm the bundle is from %s", distribution)
+		return distribution, nil
+	}
+	if detected != distribution && confidence >= publish.MinDetectionConfidence {
+		return "", errors.ErrDistMismatchDetected(string(distribution), string(detected))
+	}
+	return distribution, nil
+}
+
+func getPassword(cmd *cobra.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.ErrTooManyArguments(1)
+	}
+
+	outputURL, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if !output.RequiresPassword(outputURL) {
 		return nil
 	}
 
-	return survey.AskOne(
-		&survey.Password{
-			Message: "please enter the opensearch password",
-		},
-		&password,
-		survey.WithValidator(survey.Required),
-	)
+	return promptForPassword(cmd)
 }
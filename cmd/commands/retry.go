@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"context"
+	"os"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"github.com/dbason/opni-supportagent/pkg/output"
+	"github.com/dbason/opni-supportagent/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+const (
+	retrySuffix = ".retry"
+)
+
+func BuildRetryFailedCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:     "retry-failed file",
+		Short:   "publish the logs that failed to publish again",
+		PreRunE: getRetryPassword,
+		RunE:    retryFailed,
+	}
+
+	command.Flags().String("output", "", "where to publish the logs, takes the same values as publish --output")
+	addIndexerFlags(command)
+	addFailureFlags(command)
+
+	return command
+}
+
+// retryFailed publishes the logs in a failed logs file.  Logs that fail again
+// replace the contents of the file, and the file is removed once all of them
+// have been published.
+func retryFailed(cmd *cobra.Command, args []string) (err error) {
+	caseNumber, err := cmd.Flags().GetString("case-number")
+	if err != nil {
+		return err
+	}
+	endpoint, err := cmd.Flags().GetString("endpoint")
+	if err != nil {
+		return err
+	}
+	username, err := cmd.Flags().GetString("username")
+	if err != nil {
+		return err
+	}
+	outputURL, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	indexerConfig, err := getIndexerConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	sink, err := output.NewSink(outputURL, endpoint, username, password, indexerConfig)
+	if err != nil {
+		return err
+	}
+
+	path := args[0]
+	failed := deadLetter(sink, path+retrySuffix)
+
+	defer func() {
+		// Flush the logs that were read even if the command was interrupted
+		if closeErr := sink.Close(context.Background()); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		stats := sink.Stats()
+		util.Log.Infof("case %s logs: %d flushed, %d skipped, %d failed", caseNumber, stats.NumFlushed, stats.NumSkipped, stats.NumFailed)
+		if failed == nil {
+			return
+		}
+		if closeErr := failed.Close(context.Background()); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		failed.Report()
+
+		switch {
+		case err != nil:
+			// Leave the original file so the retry can be run again
+			os.Remove(failed.Path())
+		case failed.Total() == 0:
+			if removeErr := os.Remove(path); removeErr != nil {
+				util.Log.Warnf("unable to remove %s: %s", path, removeErr)
+			}
+		default:
+			if renameErr := os.Rename(failed.Path(), path); renameErr != nil {
+				util.Log.Warnf("unable to update %s: %s", path, renameErr)
+				break
+			}
+			util.Log.Warnf("logs that failed again were written to %s", path)
+		}
+		if err == nil {
+			err = checkFailures(cmd, stats)
+		}
+	}()
+
+	retried, err := input.ImportNDJSON(cmd.Context(), path, caseNumber, sink)
+	util.Log.Infof("read %d logs from %s", retried, path)
+	if cmd.Context().Err() != nil {
+		return errors.ErrInterrupted
+	}
+	return err
+}
+
+func getRetryPassword(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.ErrInvalidArgumentNumber(1)
+	}
+
+	outputURL, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if !output.RequiresPassword(outputURL) {
+		return nil
+	}
+
+	return promptForPassword(cmd)
+}
@@ -3,8 +3,12 @@ package cmd
 import (
 	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/dbason/opni-supportagent/cmd/commands"
+	"github.com/dbason/opni-supportagent/pkg/publish"
+	"github.com/dbason/opni-supportagent/pkg/util"
 	"github.com/spf13/cobra"
 )
 
@@ -18,10 +22,12 @@ func BuildRootCmd() *cobra.Command {
 	}
 	rootCmd.AddCommand(commands.BuildPublishCommand())
 	rootCmd.AddCommand(commands.BuildDeleteCommand())
+	rootCmd.AddCommand(commands.BuildImportCommand())
+	rootCmd.AddCommand(commands.BuildRetryFailedCommand())
 
 	rootCmd.PersistentFlags().String("case-number", "", "case number to store the logs under")
 	rootCmd.PersistentFlags().String("endpoint", "https://opensearch-support.opni.xyz", "Opensearch endpoint to publish logs to")
-	rootCmd.PersistentFlags().String("node-name", "default-node", "node name to attach to the logs")
+	rootCmd.PersistentFlags().String("node-name", publish.DefaultNodeName, "node name to attach to the logs, defaults to the hostname in the journald logs")
 	rootCmd.PersistentFlags().String("username", "index-user", "username for Opensearch")
 	rootCmd.PersistentFlags().String("password", "", "password for Opensearch")
 
@@ -31,7 +37,24 @@ func BuildRootCmd() *cobra.Command {
 }
 
 func Execute() {
-	if err := BuildRootCmd().ExecuteContext(context.Background()); err != nil {
+	if err := BuildRootCmd().ExecuteContext(signalContext()); err != nil {
 		os.Exit(1)
 	}
 }
+
+// signalContext returns a context that is cancelled on the first interrupt so
+// commands can stop and flush what they have sent.  A second interrupt exits
+// straight away.
+func signalContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		util.Log.Warn("interrupted, flushing logs that have been read.  Interrupt again to exit immediately")
+		cancel()
+		<-signals
+		os.Exit(130)
+	}()
+	return ctx
+}
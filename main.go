@@ -1,6 +1,10 @@
 package main
 
 import (
+	// The timezone database is embedded so --timezone and the timezones in
+	// bundles can be loaded on systems without one
+	_ "time/tzdata"
+
 	"github.com/dbason/opni-supportagent/cmd"
 )
 
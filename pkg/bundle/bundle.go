@@ -0,0 +1,132 @@
+package bundle
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+)
+
+const (
+	systemInfoDir    = "systeminfo"
+	checkpointFile   = ".opni-supportagent-checkpoint.json"
+	checkpointSuffix = ".checkpoint.json"
+	failedLogsFile   = ".opni-supportagent-failed.ndjson"
+	failedLogsSuffix = ".failed.ndjson"
+)
+
+// Bundle is a read only view of a log collector bundle.  Paths are relative
+// to the root of the bundle, e.g. systeminfo/date.
+type Bundle struct {
+	fs.FS
+	closer io.Closer
+}
+
+// Open returns the bundle at path, which may be a directory or a .tar.gz,
+// .tgz or .zip archive.  Files in zip archives are read directly from the
+// archive, tarballs are decompressed into a temporary directory in tempDir,
+// or the default directory for temporary files if it is empty, that is
+// removed when the bundle is closed.
+func Open(path string, tempDir string) (*Bundle, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		fsys   fs.FS
+		closer io.Closer
+	)
+	switch {
+	case info.IsDir():
+		fsys = os.DirFS(path)
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		var tarFS *tarFS
+		tarFS, err = extractTar(path, tempDir, ".")
+		if err == nil {
+			fsys, closer = tarFS, tarFS
+		}
+	case strings.HasSuffix(path, ".zip"):
+		var zipReader *zip.ReadCloser
+		zipReader, err = zip.OpenReader(path)
+		fsys, closer = zipReader, zipReader
+	default:
+		return nil, errors.ErrUnsupportedBundleWithPath(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := findRoot(fsys)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, err
+	}
+
+	return &Bundle{
+		FS:     root,
+		closer: closer,
+	}, nil
+}
+
+// Close releases the archive backing the bundle.
+func (b *Bundle) Close() error {
+	if b.closer == nil {
+		return nil
+	}
+	return b.closer.Close()
+}
+
+// findRoot descends into the single top level directory that archives of a
+// bundle are usually created with.
+func findRoot(fsys fs.FS) (fs.FS, error) {
+	dir, err := rootDir(fsys)
+	if err != nil || dir == "." {
+		return fsys, err
+	}
+	return fs.Sub(fsys, dir)
+}
+
+// rootDir returns the directory findRoot descends into.
+func rootDir(fsys fs.FS) (string, error) {
+	dir := "."
+	for {
+		if _, err := fs.Stat(fsys, path.Join(dir, systemInfoDir)); err == nil {
+			return dir, nil
+		}
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return "", err
+		}
+		if len(entries) != 1 || !entries[0].IsDir() {
+			return dir, nil
+		}
+		dir = path.Join(dir, entries[0].Name())
+	}
+}
+
+// CheckpointPath returns where the publish checkpoint for the bundle at path
+// is kept.  It is stored inside bundle directories and next to archives.
+func CheckpointPath(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return filepath.Join(path, checkpointFile)
+	}
+	return path + checkpointSuffix
+}
+
+// FailedLogsPath returns where the logs that couldn't be published from the
+// bundle at path are written.  Like the checkpoint it is stored inside bundle
+// directories and next to archives.
+func FailedLogsPath(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return filepath.Join(path, failedLogsFile)
+	}
+	return path + failedLogsSuffix
+}
@@ -0,0 +1,259 @@
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	agenterrors "github.com/dbason/opni-supportagent/pkg/errors"
+)
+
+// writeTar writes the files to a gzipped tarball at path, in name order.
+func writeTar(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, name := range sortedNames(files) {
+		err := tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(files[name])),
+			ModTime:  time.Date(2022, time.January, 2, 16, 0, 0, 0, time.UTC),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeZip writes the files to a zip archive at path, in name order.
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zipWriter := zip.NewWriter(file)
+	for _, name := range sortedNames(files) {
+		writer, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sortedNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkEmpty fails the test if anything is left in the directory.
+func checkEmpty(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d entries left in %s, want none", len(entries), dir)
+	}
+}
+
+var bundleFiles = map[string]string{
+	"bundle/systeminfo/date":     "Sun Jan  2 16:00:00 UTC 2022\n",
+	"bundle/systeminfo/hostname": "node1\n",
+	"bundle/journald/k3s":        "Jan 02 15:04:05 node1 k3s[1]: Starting k3s\n",
+}
+
+func TestOpen(t *testing.T) {
+	for _, archive := range []struct {
+		name  string
+		write func(*testing.T, string, map[string]string)
+	}{
+		{"bundle.tar.gz", writeTar},
+		{"bundle.tgz", writeTar},
+		{"bundle.zip", writeZip},
+	} {
+		t.Run(archive.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), archive.name)
+			archive.write(t, path, bundleFiles)
+			tempDir := t.TempDir()
+
+			supportBundle, err := Open(path, tempDir)
+			if err != nil {
+				t.Fatalf("Open error: %s", err)
+			}
+			if err := fstest.TestFS(supportBundle, "systeminfo/date", "systeminfo/hostname", "journald/k3s"); err != nil {
+				t.Error(err)
+			}
+			data, err := fs.ReadFile(supportBundle, "journald/k3s")
+			if err != nil || string(data) != bundleFiles["bundle/journald/k3s"] {
+				t.Errorf("ReadFile = %q, %v, want the file", data, err)
+			}
+			if err := supportBundle.Close(); err != nil {
+				t.Fatal(err)
+			}
+			checkEmpty(t, tempDir)
+		})
+	}
+}
+
+func TestOpenUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.rar")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, ""); !errors.Is(err, agenterrors.ErrUnsupportedBundle) {
+		t.Errorf("Open error = %v, want %s", err, agenterrors.ErrUnsupportedBundle)
+	}
+}
+
+func TestIndexTar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	writeTar(t, path, bundleFiles)
+
+	index, err := indexTar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := fs.ReadDir(index, "bundle/systeminfo")
+	if err != nil || len(entries) != 2 {
+		t.Errorf("ReadDir = %v, %v, want date and hostname", entries, err)
+	}
+	info, err := fs.Stat(index, "bundle/journald/k3s")
+	if err != nil || info.Size() != int64(len(bundleFiles["bundle/journald/k3s"])) {
+		t.Errorf("Stat = %v, %v, want the size of the file", info, err)
+	}
+	if _, err := index.Open("bundle/journald/k3s"); !errors.Is(err, agenterrors.ErrNotExtracted) {
+		t.Errorf("Open error = %v, want %s", err, agenterrors.ErrNotExtracted)
+	}
+}
+
+var collectionFiles = map[string]string{
+	"cluster/a/systeminfo/date":     "Sun Jan  2 16:00:00 UTC 2022\n",
+	"cluster/a/systeminfo/hostname": "node1\n",
+	"cluster/a/journald/k3s":        "node1 log\n",
+	"cluster/b/systeminfo/date":     "Sun Jan  2 16:00:00 UTC 2022\n",
+	"cluster/b/journald/k3s":        "node2 log\n",
+}
+
+func TestOpenCollection(t *testing.T) {
+	for _, archive := range []struct {
+		name  string
+		write func(*testing.T, string, map[string]string)
+	}{
+		{"cluster.tar.gz", writeTar},
+		{"cluster.zip", writeZip},
+	} {
+		t.Run(archive.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), archive.name)
+			archive.write(t, path, collectionFiles)
+			tempDir := t.TempDir()
+
+			collection, err := OpenCollection(path, tempDir)
+			if err != nil {
+				t.Fatalf("OpenCollection error: %s", err)
+			}
+			defer collection.Close()
+			if len(collection.Nodes) != 2 {
+				t.Fatalf("found %d nodes, want 2", len(collection.Nodes))
+			}
+			// Nothing is decompressed until a node is opened
+			checkEmpty(t, tempDir)
+
+			for i, want := range []struct {
+				name string
+				log  string
+			}{
+				{"node1", "node1 log\n"},
+				{"b", "node2 log\n"},
+			} {
+				node := collection.Nodes[i]
+				nodeBundle, err := node.Open()
+				if err != nil {
+					t.Fatalf("Open node %d error: %s", i, err)
+				}
+				if node.Name != want.name {
+					t.Errorf("node %d is named %s, want %s", i, node.Name, want.name)
+				}
+				data, err := fs.ReadFile(nodeBundle, "journald/k3s")
+				if err != nil || string(data) != want.log {
+					t.Errorf("node %d log = %q, %v, want %q", i, data, err, want.log)
+				}
+				if err := nodeBundle.Close(); err != nil {
+					t.Fatal(err)
+				}
+				checkEmpty(t, tempDir)
+			}
+		})
+	}
+}
+
+func TestOpenCollectionDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTar(t, filepath.Join(dir, "a.tar.gz"), bundleFiles)
+	writeZip(t, filepath.Join(dir, "b.zip"), map[string]string{
+		"systeminfo/date": "Sun Jan  2 16:00:00 UTC 2022\n",
+	})
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	tempDir := t.TempDir()
+
+	collection, err := OpenCollection(dir, tempDir)
+	if err != nil {
+		t.Fatalf("OpenCollection error: %s", err)
+	}
+	defer collection.Close()
+
+	var names []string
+	for _, node := range collection.Nodes {
+		nodeBundle, err := node.Open()
+		if err != nil {
+			t.Fatalf("Open node %s error: %s", node.Name, err)
+		}
+		if _, err := fs.Stat(nodeBundle, "systeminfo/date"); err != nil {
+			t.Errorf("node %s: %s", node.Name, err)
+		}
+		nodeBundle.Close()
+		names = append(names, node.Name)
+	}
+	if len(names) != 2 || names[0] != "node1" || names[1] != "b" {
+		t.Errorf("nodes = %v, want [node1 b]", names)
+	}
+	checkEmpty(t, tempDir)
+}
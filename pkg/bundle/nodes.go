@@ -0,0 +1,200 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bufio"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+)
+
+const (
+	hostnameFile = "systeminfo/hostname"
+)
+
+// Node is the bundle collected from a single node.  Nodes are opened one at a
+// time so only the node being published is decompressed.
+type Node struct {
+	// Name is the name of the node bundle in the collection until the node is
+	// opened, then the hostname recorded in the bundle if it has one.
+	Name string
+	open func() (*Bundle, error)
+}
+
+// Open returns the bundle of the node, which must be closed once it has been
+// published.
+func (n *Node) Open() (*Bundle, error) {
+	nodeBundle, err := n.open()
+	if err != nil {
+		return nil, err
+	}
+	if hostname := readHostname(nodeBundle); hostname != "" {
+		n.Name = hostname
+	}
+	return nodeBundle, nil
+}
+
+// Collection holds the bundles collected from several nodes of a cluster.
+type Collection struct {
+	Nodes   []*Node
+	closers []io.Closer
+}
+
+// OpenCollection returns the bundle of every node in the directory, or
+// archive, at collectionPath.  A directory may hold the node bundles as directories or
+// as archives, an archive may only hold directories.  Tarballs are
+// decompressed into tempDir as each node is opened.
+func OpenCollection(collectionPath string, tempDir string) (*Collection, error) {
+	info, err := os.Stat(collectionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := &Collection{}
+	if info.IsDir() {
+		entries, err := os.ReadDir(collectionPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && !isArchive(entry.Name()) {
+				continue
+			}
+			nodePath := filepath.Join(collectionPath, entry.Name())
+			collection.add(entry.Name(), func() (*Bundle, error) {
+				return Open(nodePath, tempDir)
+			})
+		}
+		return collection, nil
+	}
+
+	// Only the headers of a tarball are read to find the nodes, each node's
+	// files are decompressed when it is opened.
+	var (
+		archive  fs.FS
+		openNode func(dir string) (*Bundle, error)
+	)
+	switch {
+	case strings.HasSuffix(collectionPath, ".zip"):
+		zipReader, err := zip.OpenReader(collectionPath)
+		if err != nil {
+			return nil, err
+		}
+		collection.closers = append(collection.closers, zipReader)
+		archive = zipReader
+		openNode = func(dir string) (*Bundle, error) {
+			return subBundle(zipReader, dir, nil)
+		}
+	case isArchive(collectionPath):
+		archive, err = indexTar(collectionPath)
+		if err != nil {
+			return nil, err
+		}
+		openNode = func(dir string) (*Bundle, error) {
+			tarFS, err := extractTar(collectionPath, tempDir, dir)
+			if err != nil {
+				return nil, err
+			}
+			return subBundle(tarFS, dir, tarFS)
+		}
+	default:
+		return nil, errors.ErrUnsupportedBundleWithPath(collectionPath)
+	}
+	root, err := rootDir(archive)
+	if err != nil {
+		collection.Close()
+		return nil, err
+	}
+
+	// The archive only holds a single node.
+	if _, err := fs.Stat(archive, path.Join(root, systemInfoDir)); err == nil {
+		collection.add(filepath.Base(collectionPath), func() (*Bundle, error) {
+			return Open(collectionPath, tempDir)
+		})
+		return collection, nil
+	}
+
+	entries, err := fs.ReadDir(archive, root)
+	if err != nil {
+		collection.Close()
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := path.Join(root, entry.Name())
+		collection.add(entry.Name(), func() (*Bundle, error) {
+			return openNode(dir)
+		})
+	}
+	return collection, nil
+}
+
+// subBundle returns the bundle in the directory of the archive.  closer, if
+// set, is closed with the bundle.
+func subBundle(archive fs.FS, dir string, closer io.Closer) (*Bundle, error) {
+	sub, err := fs.Sub(archive, dir)
+	if err == nil {
+		sub, err = findRoot(sub)
+	}
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, err
+	}
+	return &Bundle{
+		FS:     sub,
+		closer: closer,
+	}, nil
+}
+
+func (c *Collection) add(bundleName string, open func() (*Bundle, error)) {
+	c.Nodes = append(c.Nodes, &Node{
+		Name: trimArchiveSuffix(bundleName),
+		open: open,
+	})
+}
+
+// Close releases the archive holding the node bundles.  The bundle of each
+// node is closed by whoever opened it.
+func (c *Collection) Close() error {
+	var err error
+	for _, closer := range c.closers {
+		if closeErr := closer.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func readHostname(fsys fs.FS) string {
+	file, err := fsys.Open(hostnameFile)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan()
+	return strings.TrimSpace(scanner.Text())
+}
+
+func isArchive(name string) bool {
+	return trimArchiveSuffix(name) != name
+}
+
+func trimArchiveSuffix(name string) string {
+	for _, suffix := range []string{".tar.gz", ".tgz", ".zip"} {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
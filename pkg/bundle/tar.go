@@ -0,0 +1,307 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+)
+
+// tarFS is a filesystem backed by a gzipped tarball.  Tarballs can't be read
+// at random, and the files of a bundle are opened many times and at the same
+// time, so the files under one directory of the archive are decompressed in a
+// single pass into a temporary directory that they are read from.  The
+// headers of every entry are kept in memory, so the layout of the whole
+// archive can be read without decompressing any files.
+type tarFS struct {
+	// dir is the temporary directory the files are decompressed into, empty
+	// if none are.
+	dir string
+	// extracted is the directory of the archive whose files are in dir.
+	extracted string
+	entries   map[string]fs.FileInfo
+	children  map[string][]string
+}
+
+// indexTar reads the headers of the archive without decompressing any of its
+// files, which can't be opened.
+func indexTar(archivePath string) (*tarFS, error) {
+	t := newTarFS()
+	if err := t.read(archivePath); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// extractTar decompresses the files under the directory of the archive,
+// "." for all of them, into a temporary directory created in tempDir, or the
+// default directory for temporary files if tempDir is empty.  The temporary
+// directory is removed when the filesystem is closed.
+func extractTar(archivePath string, tempDir string, dir string) (*tarFS, error) {
+	spoolDir, err := os.MkdirTemp(tempDir, "opni-supportagent-")
+	if err != nil {
+		return nil, err
+	}
+	t := newTarFS()
+	t.dir = spoolDir
+	t.extracted = dir
+	if err := t.read(archivePath); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func newTarFS() *tarFS {
+	return &tarFS{
+		entries: map[string]fs.FileInfo{
+			".": dirInfo("."),
+		},
+		children: map[string][]string{},
+	}
+}
+
+// read reads the archive in order, recording each entry and writing the
+// files to be extracted to the temporary directory.
+func (t *tarFS) read(archivePath string) error {
+	file, tarReader, err := openTar(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := entryName(header)
+		if !fs.ValidPath(name) {
+			continue
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			t.add(name, header.FileInfo())
+		case tar.TypeReg, tar.TypeRegA:
+			if info, ok := t.entries[name]; ok && info.IsDir() {
+				continue
+			}
+			if t.isExtracted(name) {
+				if err := t.spool(name, tarReader); err != nil {
+					return err
+				}
+			}
+			t.add(name, header.FileInfo())
+		}
+	}
+
+	for _, names := range t.children {
+		sort.Strings(names)
+	}
+	return nil
+}
+
+// isExtracted returns whether the file is decompressed into the temporary
+// directory.
+func (t *tarFS) isExtracted(name string) bool {
+	if t.dir == "" {
+		return false
+	}
+	return t.extracted == "." || strings.HasPrefix(name, t.extracted+"/")
+}
+
+// spool writes the file to the temporary directory.
+func (t *tarFS) spool(name string, r io.Reader) error {
+	spooled := t.spooledPath(name)
+	if err := os.MkdirAll(filepath.Dir(spooled), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(spooled, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (t *tarFS) spooledPath(name string) string {
+	return filepath.Join(t.dir, filepath.FromSlash(name))
+}
+
+// Close removes the temporary directory the archive was decompressed into.
+func (t *tarFS) Close() error {
+	if t.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(t.dir)
+}
+
+func openTar(archivePath string) (*os.File, *tar.Reader, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, tar.NewReader(gzipReader), nil
+}
+
+func entryName(header *tar.Header) string {
+	return path.Clean(strings.TrimPrefix(header.Name, "/"))
+}
+
+// add records the entry and any parent directories that aren't in the
+// archive.
+func (t *tarFS) add(name string, info fs.FileInfo) {
+	if _, ok := t.entries[name]; ok {
+		if info.IsDir() {
+			t.entries[name] = info
+		}
+		return
+	}
+	t.entries[name] = info
+	if name == "." {
+		return
+	}
+	parent := path.Dir(name)
+	t.children[parent] = append(t.children[parent], name)
+	if _, ok := t.entries[parent]; !ok {
+		t.add(parent, dirInfo(parent))
+	}
+}
+
+func (t *tarFS) Open(name string) (fs.File, error) {
+	info, err := t.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		entries, err := t.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &tarDir{
+			info:    info,
+			entries: entries,
+		}, nil
+	}
+
+	if !t.isExtracted(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrNotExtracted}
+	}
+	file, err := os.Open(t.spooledPath(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &tarFile{
+		info: info,
+		file: file,
+	}, nil
+}
+
+func (t *tarFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, ok := t.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return info, nil
+}
+
+func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := t.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries := make([]fs.DirEntry, 0, len(t.children[name]))
+	for _, child := range t.children[name] {
+		entries = append(entries, fs.FileInfoToDirEntry(t.entries[child]))
+	}
+	return entries, nil
+}
+
+// tarFile is a file of the archive, read from the temporary directory.
+type tarFile struct {
+	info fs.FileInfo
+	file *os.File
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *tarFile) Read(b []byte) (int, error) {
+	return f.file.Read(b)
+}
+
+func (f *tarFile) Close() error {
+	return f.file.Close()
+}
+
+type tarDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *tarDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *tarDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: fs.ErrInvalid}
+}
+
+func (d *tarDir) Close() error {
+	return nil
+}
+
+func (d *tarDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	d.offset += count
+	return remaining[:count], nil
+}
+
+// dirInfo describes a directory that is implied by the files in an archive
+// but doesn't have its own entry.
+type dirInfo string
+
+func (d dirInfo) Name() string       { return path.Base(string(d)) }
+func (d dirInfo) Size() int64        { return 0 }
+func (d dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+func (d dirInfo) IsDir() bool        { return true }
+func (d dirInfo) Sys() interface{}   { return nil }
@@ -6,9 +6,26 @@ import (
 )
 
 var (
-	ErrQueueDelete      = errors.New("failed to queue delete")
-	ErrInvalidDist      = errors.New("distribution must be one of rke, rke2, k3s")
-	ErrInvalidArguments = errors.New("invalid arguments")
+	ErrQueueDelete       = errors.New("failed to queue delete")
+	ErrInvalidDist       = errors.New("distribution must be one of rke, rke2, k3s")
+	ErrInvalidArguments  = errors.New("invalid arguments")
+	ErrUnknownDist       = errors.New("unable to detect distribution from bundle, please specify the cluster type")
+	ErrDistMismatch      = errors.New("cluster type does not match the bundle contents")
+	ErrInvalidLayout     = errors.New("invalid bundle layout")
+	ErrMissingLogs       = errors.New("required logs are missing from the bundle")
+	ErrUnknownOutput     = errors.New("output must be empty or start with file://, opni+ or loki+")
+	ErrPayloadRejected   = errors.New("payload was rejected")
+	ErrCaseMismatch      = errors.New("logs belong to a different case")
+	ErrNoNodeBundles     = errors.New("no node bundles found")
+	ErrPublishFailed     = errors.New("publish failed")
+	ErrUnsupportedBundle = errors.New("bundle must be a directory or a .tar.gz, .tgz or .zip archive")
+	ErrTooManyFailures   = errors.New("too many logs failed to publish")
+	ErrInterrupted       = errors.New("interrupted")
+	ErrInvalidTimestamp  = errors.New("unable to parse timestamp")
+	ErrInvalidPolicy     = errors.New("unparsed must be one of skip, attach, index")
+	ErrInvalidTimezone   = errors.New("timezone must be an IANA timezone name, e.g. America/Chicago")
+	ErrUnreadableFile    = errors.New("file could not be read to the end")
+	ErrNotExtracted      = errors.New("file has not been extracted from the archive")
 )
 
 func ErrQueueDeleteWithResp(resp string) error {
@@ -18,3 +35,61 @@ func ErrQueueDeleteWithResp(resp string) error {
 func ErrInvalidArgumentNumber(numRequired int) error {
 	return fmt.Errorf("command requires %d arguments: %w", numRequired, ErrInvalidArguments)
 }
+
+func ErrInvalidLayoutWithReason(reason string) error {
+	return fmt.Errorf("%s: %w", reason, ErrInvalidLayout)
+}
+
+func ErrMissingComponent(component string) error {
+	return fmt.Errorf("%s: %w", component, ErrMissingLogs)
+}
+
+func ErrTooManyArguments(maxArguments int) error {
+	return fmt.Errorf("command accepts at most %d arguments: %w", maxArguments, ErrInvalidArguments)
+}
+
+func ErrDistMismatchDetected(specified string, detected string) error {
+	return fmt.Errorf("%s specified but bundle looks like %s: %w", specified, detected, ErrDistMismatch)
+}
+
+func ErrUnsupportedBundleWithPath(path string) error {
+	return fmt.Errorf("%s: %w", path, ErrUnsupportedBundle)
+}
+
+func ErrNodesFailed(failed int, total int) error {
+	return fmt.Errorf("%d of %d nodes: %w", failed, total, ErrPublishFailed)
+}
+
+func ErrCaseMismatchWithID(caseNumber string) error {
+	return fmt.Errorf("found logs for case %s: %w", caseNumber, ErrCaseMismatch)
+}
+
+func ErrPayloadRejectedWithResp(status string, resp string) error {
+	return fmt.Errorf("%s %s: %w", status, resp, ErrPayloadRejected)
+}
+
+func ErrUnknownOutputWithURL(outputURL string) error {
+	return fmt.Errorf("%s: %w", outputURL, ErrUnknownOutput)
+}
+
+func ErrTooManyFailuresWithCount(failed uint64, total uint64) error {
+	return fmt.Errorf("%d of %d logs: %w", failed, total, ErrTooManyFailures)
+}
+
+func ErrInvalidTimestampWithValue(value string, reason string) error {
+	return fmt.Errorf("%q: %s: %w", value, reason, ErrInvalidTimestamp)
+}
+
+func ErrInvalidTimezoneWithName(name string) error {
+	return fmt.Errorf("%s: %w", name, ErrInvalidTimezone)
+}
+
+func ErrUnreadableFileWithPath(path string, reason string) error {
+	return fmt.Errorf("%s: %s: %w", path, reason, ErrUnreadableFile)
+}
+
+// IsUnreadableFile returns whether the error is from a file that couldn't be
+// read to the end.
+func IsUnreadableFile(err error) bool {
+	return errors.Is(err, ErrUnreadableFile)
+}
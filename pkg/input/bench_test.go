@@ -0,0 +1,151 @@
+package input
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var benchCaptured = time.Date(2022, time.January, 2, 16, 0, 0, 0, time.UTC)
+
+// The lines are representative of the logs in RKE, RKE2 and K3s bundles.
+var (
+	rkeDockerKlogLines = []string{
+		`{"log":"I0102 15:04:05.123456       1 controller.go:611] quota admission added evaluator for: leases.coordination.k8s.io\n","stream":"stderr","time":"2022-01-02T15:04:05.123456789Z"}`,
+		`{"log":"E0102 15:04:05.223456       1 status.go:71] apiserver received an error that is not an metav1.Status: context canceled\n","stream":"stderr","time":"2022-01-02T15:04:05.223456789Z"}`,
+		`{"log":"\tgoroutine 1 [running]:\n","stream":"stderr","time":"2022-01-02T15:04:05.223556789Z"}`,
+	}
+	rkeDockerEtcdLines = []string{
+		`{"log":"2022-01-02 15:04:05.123456 I | etcdserver: published {Name:etcd-node1 ClientURLs:[https://10.0.0.1:2379]} to cluster 3f5a\n","stream":"stderr","time":"2022-01-02T15:04:05.123556789Z"}`,
+		`{"log":"{\"level\":\"warn\",\"ts\":\"2022-01-02T15:04:05.123Z\",\"msg\":\"apply request took too long\"}\n","stream":"stderr","time":"2022-01-02T15:04:05.123656789Z"}`,
+	}
+	rke2CRIKlogLines = []string{
+		`2022-01-02T15:04:05.123456789Z stderr F I0102 15:04:05.123456       1 event.go:294] "Event occurred" object="kube-system/coredns-1" kind="Pod" apiVersion="v1" type="Normal" reason="Scheduled"`,
+		`2022-01-02T15:04:05.223456789Z stderr F E0102 15:04:05.223456       1 reflector.go:138] k8s.io/client-go/informers/factory.go:134: Failed to watch *v1.Node: unknown`,
+		`2022-01-02T15:04:05.323456789Z stderr P I0102 15:04:05.323456       1 trace.go:205] Trace[1]: "List" url:/api/v1/pods`,
+		`2022-01-02T15:04:05.323456789Z stderr F  (02-Jan-2022 15:04:05.000) (total time: 1000ms)`,
+	}
+	rke2EtcdLines = []string{
+		`{"level":"info","ts":"2022-01-02T15:04:05.123Z","caller":"etcdserver/server.go:2027","msg":"published local member to cluster through raft"}`,
+		`2022-01-02 15:04:05.123456 I | etcdserver: starting server... [version: 3.4.13, cluster version: to_be_decided]`,
+	}
+	k3sJournaldLines = []string{
+		`Jan 02 15:04:05 node1 k3s[856]: I0102 15:04:05.123456     856 server.go:77] Version: v1.22.5+k3s1`,
+		`Jan 02 15:04:05 node1 k3s[856]: time="2022-01-02T15:04:05Z" level=info msg="Starting k3s v1.22.5+k3s1"`,
+		`Jan  2 15:04:06 node1 k3s[856]: E0102 15:04:06.123456     856 kubelet.go:2183] "Error syncing pod" pod="kube-system/coredns-1" err="failed"`,
+		`Jan  2 15:04:06 node1 systemd[1]: Started Lightweight Kubernetes.`,
+	}
+	rancherLines = []string{
+		`2022/01/02 15:04:05 [INFO] Starting rancher-system-agent`,
+		`I0102 15:04:05.123456      42 leaderelection.go:248] attempting to acquire leader lease kube-system/rancher`,
+		`  continued on the next line`,
+	}
+)
+
+// benchmarkParser parses and annotates the lines with the parser, as is
+// done for every line of a file.
+func benchmarkParser(b *testing.B, parser DateParser, lines []string) {
+	annotator, _ := parser.(Annotator)
+	var size int64
+	for _, line := range lines {
+		size += int64(len(line)) + 1
+	}
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			_, _, valid, err := parser.ParseTimestamp(line)
+			if err == nil && valid && annotator != nil {
+				var log LogMessage
+				annotator.Annotate(line, &log)
+			}
+		}
+	}
+}
+
+func BenchmarkRKEDockerKlog(b *testing.B) {
+	benchmarkParser(b, NewKlogParser(NewDockerParser(KlogRegex)), rkeDockerKlogLines)
+}
+
+func BenchmarkRKEDockerEtcd(b *testing.B) {
+	benchmarkParser(b, NewDockerParser(EtcdRegex, EtcdJSONRegex), rkeDockerEtcdLines)
+}
+
+func BenchmarkRKE2CRIKlog(b *testing.B) {
+	benchmarkParser(b, NewKlogParser(NewCRIParser(KlogRegex)), rke2CRIKlogLines)
+}
+
+func BenchmarkRKE2Etcd(b *testing.B) {
+	benchmarkParser(b, NewRKE2EtcdParser(), rke2EtcdLines)
+}
+
+func BenchmarkK3sJournald(b *testing.B) {
+	benchmarkParser(b, NewKlogParser(NewJournaldParser(benchCaptured)), k3sJournaldLines)
+}
+
+func BenchmarkRancher(b *testing.B) {
+	benchmarkParser(b, NewKlogParser(NewMultipleParser(
+		Dateformat{
+			DateRegex: RancherRegex,
+			Layout:    RancherLayout,
+		},
+		Dateformat{
+			DateRegex: KlogRegex,
+			Layout:    KlogLayout,
+			Captured:  benchCaptured,
+		},
+	)), rancherLines)
+}
+
+// BenchmarkPublishK3sJournald reads a whole file, including splitting it into
+// lines and batching the logs.
+func BenchmarkPublishK3sJournald(b *testing.B) {
+	content := strings.Repeat(strings.Join(k3sJournaldLines, "\n")+"\n", 1000)
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		publishTestFile(b, NewKlogParser(NewJournaldParser(benchCaptured)), content)
+	}
+}
+
+// The matcher benchmarks compare finding timestamps by shape with the regex
+// they replace.
+
+func benchmarkFind(b *testing.B, find func(string) (int, int), lines []string) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			find(line)
+		}
+	}
+}
+
+func regexFind(pattern string) func(string) (int, int) {
+	re := regexp.MustCompile(pattern)
+	return func(log string) (int, int) {
+		loc := re.FindStringIndex(log)
+		if loc == nil {
+			return -1, -1
+		}
+		return loc[0], loc[1]
+	}
+}
+
+func BenchmarkKlogMatcher(b *testing.B) {
+	benchmarkFind(b, newTimestampMatcher(KlogRegex).find, rke2CRIKlogLines)
+}
+
+func BenchmarkKlogRegex(b *testing.B) {
+	benchmarkFind(b, regexFind(KlogRegex), rke2CRIKlogLines)
+}
+
+func BenchmarkJournaldMatcher(b *testing.B) {
+	benchmarkFind(b, newTimestampMatcher(JournaldRegex).find, k3sJournaldLines)
+}
+
+func BenchmarkJournaldRegex(b *testing.B) {
+	benchmarkFind(b, regexFind(JournaldRegex), k3sJournaldLines)
+}
@@ -0,0 +1,191 @@
+package input
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/util"
+)
+
+const (
+	checkpointInterval = 5 * time.Second
+)
+
+// Checkpoint records how far through each file the logs have been stored by
+// the sink, so an interrupted publish can carry on where it stopped.  The
+// offset kept for each file is the position of the first log that hasn't
+// been stored, everything before it has.
+type Checkpoint struct {
+	CaseNumber string           `json:"case_number"`
+	Files      map[string]int64 `json:"files"`
+
+	mu        sync.Mutex
+	path      string
+	resumeAt  map[string]int64
+	pending   map[string]*pendingLogs
+	lastSaved time.Time
+}
+
+// pendingLogs are the logs from a file that have been written to the sink
+// but not yet stored.
+type pendingLogs struct {
+	offsets []int64
+	stored  map[int64]bool
+}
+
+// LoadCheckpoint returns the checkpoint stored at path.  If resume is false,
+// or the checkpoint is for a different case, an empty checkpoint is returned.
+func LoadCheckpoint(path string, caseNumber string, resume bool) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{
+		CaseNumber: caseNumber,
+		Files:      map[string]int64{},
+		path:       path,
+		resumeAt:   map[string]int64{},
+		pending:    map[string]*pendingLogs{},
+	}
+	if !resume {
+		return checkpoint, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			util.Log.Warnf("no checkpoint found at %s, publishing all logs", path)
+			return checkpoint, nil
+		}
+		return nil, err
+	}
+
+	saved := &Checkpoint{}
+	if err := json.Unmarshal(data, saved); err != nil {
+		return nil, err
+	}
+	if saved.CaseNumber != caseNumber {
+		util.Log.Warnf("checkpoint is for case %s, publishing all logs", saved.CaseNumber)
+		return checkpoint, nil
+	}
+	for file, offset := range saved.Files {
+		checkpoint.Files[file] = offset
+		checkpoint.resumeAt[file] = offset
+	}
+	return checkpoint, nil
+}
+
+func checkpointKey(nodeName string, bundleFile string) string {
+	return nodeName + "/" + bundleFile
+}
+
+// Stored returns whether the log starting at offset in the file was stored by
+// a previous publish.
+func (c *Checkpoint) Stored(nodeName string, bundleFile string, offset int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resumeAt, ok := c.resumeAt[checkpointKey(nodeName, bundleFile)]
+	return ok && offset < resumeAt
+}
+
+// Track should be called before the log is written to the sink.
+func (c *Checkpoint) Track(log LogMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := checkpointKey(log.NodeName, log.BundleFile)
+	pending, ok := c.pending[key]
+	if !ok {
+		pending = &pendingLogs{
+			stored: map[int64]bool{},
+		}
+		c.pending[key] = pending
+	}
+	pending.offsets = append(pending.offsets, log.BundleOffset)
+	if _, ok := c.Files[key]; !ok {
+		c.Files[key] = log.BundleOffset
+	}
+}
+
+// Acknowledge should be called by the sink once the log has been stored.
+func (c *Checkpoint) Acknowledge(log LogMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := checkpointKey(log.NodeName, log.BundleFile)
+	pending, ok := c.pending[key]
+	if !ok {
+		return
+	}
+	pending.stored[log.BundleOffset] = true
+
+	// Logs can be stored out of order so only move the checkpoint past the
+	// logs that have all been stored.
+	for len(pending.offsets) > 0 && pending.stored[pending.offsets[0]] {
+		offset := pending.offsets[0]
+		delete(pending.stored, offset)
+		pending.offsets = pending.offsets[1:]
+		c.Files[key] = offset + 1
+	}
+	if len(pending.offsets) > 0 {
+		c.Files[key] = pending.offsets[0]
+	}
+
+	if time.Since(c.lastSaved) > checkpointInterval {
+		if err := c.save(); err != nil {
+			util.Log.Warnf("unable to save checkpoint: %s", err)
+		}
+	}
+}
+
+// Save writes the checkpoint to disk.
+func (c *Checkpoint) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.save()
+}
+
+func (c *Checkpoint) save() error {
+	c.lastSaved = time.Now()
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so an interrupted save doesn't leave a
+	// corrupt checkpoint behind.
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// Remove deletes the checkpoint once everything has been published.  The
+// checkpoint is kept, and false returned, while any log tracked hasn't been
+// stored.
+func (c *Checkpoint) Remove() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, pending := range c.pending {
+		if len(pending.offsets) > 0 {
+			return false, nil
+		}
+	}
+	err := os.Remove(c.path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,193 @@
+package input
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func checkpointLog(nodeName string, file string, offset int64) LogMessage {
+	return LogMessage{
+		NodeName:     nodeName,
+		BundleFile:   file,
+		BundleOffset: offset,
+	}
+}
+
+func TestCheckpointAcknowledge(t *testing.T) {
+	tests := []struct {
+		name         string
+		tracked      []int64
+		acknowledged []int64
+		want         int64
+	}{
+		{
+			name:    "nothing stored",
+			tracked: []int64{0, 200, 201},
+			want:    0,
+		},
+		{
+			name:         "in order",
+			tracked:      []int64{0, 200, 201},
+			acknowledged: []int64{0, 200, 201},
+			want:         202,
+		},
+		{
+			name:         "later log stored first",
+			tracked:      []int64{0, 200, 201},
+			acknowledged: []int64{200},
+			want:         0,
+		},
+		{
+			name:         "gap filled",
+			tracked:      []int64{0, 200, 201},
+			acknowledged: []int64{200, 0},
+			want:         201,
+		},
+		{
+			name:         "out of order",
+			tracked:      []int64{0, 200, 201},
+			acknowledged: []int64{201, 0, 200},
+			want:         202,
+		},
+		{
+			name:         "last log missing",
+			tracked:      []int64{0, 200, 201},
+			acknowledged: []int64{0, 201},
+			want:         200,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkpoint, err := LoadCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"), "case", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, offset := range tt.tracked {
+				checkpoint.Track(checkpointLog("node1", "log", offset))
+				// Logs of another file don't move the checkpoint of this one
+				checkpoint.Track(checkpointLog("node2", "log", offset))
+			}
+			for _, offset := range tt.acknowledged {
+				checkpoint.Acknowledge(checkpointLog("node1", "log", offset))
+			}
+			want := map[string]int64{
+				"node1/log": tt.want,
+				"node2/log": 0,
+			}
+			if !reflect.DeepEqual(checkpoint.Files, want) {
+				t.Errorf("Files = %v, want %v", checkpoint.Files, want)
+			}
+		})
+	}
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	saved, err := LoadCheckpoint(path, "case", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []int64{0, 100, 200} {
+		saved.Track(checkpointLog("node1", "log", offset))
+	}
+	saved.Acknowledge(checkpointLog("node1", "log", 0))
+	saved.Acknowledge(checkpointLog("node1", "log", 200))
+	if err := saved.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		caseNumber string
+		resume     bool
+		stored     []int64
+		notStored  []int64
+	}{
+		{
+			name:       "resume",
+			caseNumber: "case",
+			resume:     true,
+			stored:     []int64{0, 99},
+			notStored:  []int64{100, 200},
+		},
+		{
+			name:       "not resuming",
+			caseNumber: "case",
+			notStored:  []int64{0, 100},
+		},
+		{
+			name:       "another case",
+			caseNumber: "other",
+			resume:     true,
+			notStored:  []int64{0, 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkpoint, err := LoadCheckpoint(path, tt.caseNumber, tt.resume)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, offset := range tt.stored {
+				if !checkpoint.Stored("node1", "log", offset) {
+					t.Errorf("log at %d isn't stored, want stored", offset)
+				}
+			}
+			for _, offset := range tt.notStored {
+				if checkpoint.Stored("node1", "log", offset) {
+					t.Errorf("log at %d is stored, want not stored", offset)
+				}
+			}
+			if checkpoint.Stored("node2", "log", 0) {
+				t.Error("log of another node is stored, want not stored")
+			}
+		})
+	}
+
+	checkpoint, err := LoadCheckpoint(filepath.Join(t.TempDir(), "missing.json"), "case", true)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint of a missing file error: %s", err)
+	}
+	if checkpoint.Stored("node1", "log", 0) {
+		t.Error("log is stored without a checkpoint, want not stored")
+	}
+}
+
+func TestCheckpointRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := LoadCheckpoint(path, "case", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Track(checkpointLog("node1", "log", 0))
+	checkpoint.Track(checkpointLog("node1", "log", 100))
+	checkpoint.Acknowledge(checkpointLog("node1", "log", 100))
+	if err := checkpoint.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := checkpoint.Remove()
+	if err != nil || removed {
+		t.Errorf("Remove with a log not stored = %t, %v, want false", removed, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("checkpoint with a log not stored was removed: %s", err)
+	}
+
+	checkpoint.Acknowledge(checkpointLog("node1", "log", 0))
+	removed, err = checkpoint.Remove()
+	if err != nil || !removed {
+		t.Errorf("Remove with every log stored = %t, %v, want true", removed, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint with every log stored wasn't removed: %v", err)
+	}
+
+	// Removing a checkpoint that is already gone isn't an error
+	removed, err = checkpoint.Remove()
+	if err != nil || !removed {
+		t.Errorf("Remove of a removed checkpoint = %t, %v, want true", removed, err)
+	}
+}
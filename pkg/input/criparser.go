@@ -0,0 +1,124 @@
+package input
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+)
+
+const (
+	EtcdJSONRegex = `^\{"level":"`
+
+	criStdout  = "stdout"
+	criStderr  = "stderr"
+	criPartial = "P"
+)
+
+// CRIParser parses container logs written by containerd in the CRI format,
+// an RFC3339Nano timestamp, the stream, a P or F tag and the log.  Long lines
+// are split into partial lines tagged P, ending with a line tagged F, which
+// are joined back together.  Lines of stdout and stderr can be interleaved so
+// each stream is joined separately.  The CRI timestamp is the time of the log, and a
+// line starts a new log if the log matches one of the timestamp regexes.
+type CRIParser struct {
+	timestamps []*timestampMatcher
+}
+
+// criLine is a line split into its CRI fields.
+type criLine struct {
+	timestamp string
+	stream    string
+	tag       string
+	log       string
+}
+
+func NewCRIParser(timestampRegexes ...string) *CRIParser {
+	parser := &CRIParser{}
+	for _, timestampRegex := range timestampRegexes {
+		parser.timestamps = append(parser.timestamps, newTimestampMatcher(timestampRegex))
+	}
+	return parser
+}
+
+// splitCRILine splits the line into its fields, returning false if it isn't
+// a CRI line.
+func splitCRILine(line string) (criLine, bool) {
+	var cri criLine
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) < 3 {
+		return cri, false
+	}
+	cri.timestamp = fields[0]
+	cri.stream = fields[1]
+	if cri.stream != criStdout && cri.stream != criStderr {
+		return cri, false
+	}
+	// The tag may hold more than one tag separated by colons, the first is
+	// whether the line is partial
+	cri.tag = fields[2]
+	if i := strings.IndexByte(cri.tag, ':'); i >= 0 {
+		cri.tag = cri.tag[:i]
+	}
+	if len(fields) == 4 {
+		cri.log = fields[3]
+	}
+	return cri, true
+}
+
+func (p *CRIParser) ParseTimestamp(log string) (time.Time, string, bool, error) {
+	cri, ok := splitCRILine(log)
+	if !ok {
+		return time.Now(), log, false, nil
+	}
+	datetime, err := time.Parse(time.RFC3339Nano, cri.timestamp)
+	if err != nil {
+		return time.Time{}, log, false, errors.ErrInvalidTimestampWithValue(cri.timestamp, err.Error())
+	}
+
+	if len(p.timestamps) == 0 {
+		return datetime, cri.log, true, nil
+	}
+	for _, timestamp := range p.timestamps {
+		if start, _ := timestamp.find(cri.log); start >= 0 {
+			return datetime, cri.log, true, nil
+		}
+	}
+	return datetime, cri.log, false, nil
+}
+
+func (p *CRIParser) Partial(line string) bool {
+	cri, ok := splitCRILine(line)
+	return ok && cri.tag == criPartial
+}
+
+func (p *CRIParser) Stream(line string) string {
+	cri, _ := splitCRILine(line)
+	return cri.stream
+}
+
+func (p *CRIParser) Join(partials []string, line string) string {
+	if len(partials) == 0 {
+		return line
+	}
+	var b strings.Builder
+	// Keep the timestamp and stream of the first part
+	first, _ := splitCRILine(partials[0])
+	b.WriteString(first.timestamp + " " + first.stream + " F ")
+	for _, partial := range partials {
+		cri, _ := splitCRILine(partial)
+		b.WriteString(cri.log)
+	}
+	if cri, ok := splitCRILine(line); ok {
+		b.WriteString(cri.log)
+	} else {
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+func (p *CRIParser) Annotate(line string, log *LogMessage) {
+	if cri, ok := splitCRILine(line); ok {
+		log.Stream = cri.stream
+	}
+}
@@ -0,0 +1,166 @@
+package input
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitCRILine(t *testing.T) {
+	tests := []struct {
+		line string
+		cri  criLine
+		ok   bool
+	}{
+		{
+			line: "2022-01-02T15:04:05.123456789Z stdout F hello world",
+			cri:  criLine{timestamp: "2022-01-02T15:04:05.123456789Z", stream: "stdout", tag: "F", log: "hello world"},
+			ok:   true,
+		},
+		{
+			line: "2022-01-02T15:04:05.123456789Z stderr P:extra part",
+			cri:  criLine{timestamp: "2022-01-02T15:04:05.123456789Z", stream: "stderr", tag: "P", log: "part"},
+			ok:   true,
+		},
+		{
+			line: "2022-01-02T15:04:05.123456789Z stdout F",
+			cri:  criLine{timestamp: "2022-01-02T15:04:05.123456789Z", stream: "stdout", tag: "F"},
+			ok:   true,
+		},
+		{
+			line: "2022-01-02T15:04:05.123456789Z stdin F hello",
+		},
+		{
+			line: "I0102 15:04:05.123456 1 a.go:1] not CRI",
+		},
+	}
+	for _, tt := range tests {
+		cri, ok := splitCRILine(tt.line)
+		if ok != tt.ok {
+			t.Errorf("splitCRILine(%q) ok = %t, want %t", tt.line, ok, tt.ok)
+			continue
+		}
+		if ok && cri != tt.cri {
+			t.Errorf("splitCRILine(%q) = %+v, want %+v", tt.line, cri, tt.cri)
+		}
+	}
+}
+
+func TestCRIParserParseTimestamp(t *testing.T) {
+	parser := NewCRIParser(KlogRegex)
+	tests := []struct {
+		line    string
+		message string
+		valid   bool
+		err     bool
+	}{
+		{
+			line:    "2022-01-02T15:04:05.123456789Z stderr F I0102 15:04:05.123456 1 a.go:1] started",
+			message: "I0102 15:04:05.123456 1 a.go:1] started",
+			valid:   true,
+		},
+		{
+			line:    "2022-01-02T15:04:05.123456789Z stderr F goroutine 1 [running]:",
+			message: "goroutine 1 [running]:",
+		},
+		{
+			line:    "not a CRI line",
+			message: "not a CRI line",
+		},
+		{
+			line: "yesterday stdout F hello",
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		datetime, message, valid, err := parser.ParseTimestamp(tt.line)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseTimestamp(%q) error = %v, want error %t", tt.line, err, tt.err)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		if message != tt.message || valid != tt.valid {
+			t.Errorf("ParseTimestamp(%q) = %q, %t, want %q, %t", tt.line, message, valid, tt.message, tt.valid)
+		}
+		if want := time.Date(2022, time.January, 2, 15, 4, 5, 123456789, time.UTC); valid && !datetime.Equal(want) {
+			t.Errorf("ParseTimestamp(%q) time = %s, want %s", tt.line, datetime, want)
+		}
+	}
+
+	// Without timestamp regexes every CRI line is a log
+	if _, _, valid, _ := NewCRIParser().ParseTimestamp("2022-01-02T15:04:05Z stdout F anything"); !valid {
+		t.Error("CRI line without timestamp regexes isn't valid")
+	}
+}
+
+func TestCRIPartialLines(t *testing.T) {
+	content := "2022-01-02T15:04:05.100000000Z stderr F I0102 15:04:05.100000 1 a.go:1] first\n" +
+		"2022-01-02T15:04:05.200000000Z stdout P I0102 15:04:05.200000 1 a.go:2] long \n" +
+		"2022-01-02T15:04:05.200000001Z stdout P line split \n" +
+		"2022-01-02T15:04:05.200000002Z stdout F in three\n" +
+		"2022-01-02T15:04:05.300000000Z stderr F goroutine 1 [running]:\n" +
+		"2022-01-02T15:04:05.400000000Z stderr P I0102 15:04:05.400000 1 a.go:3] cut off at the end"
+
+	logs := publishTestFile(t, NewKlogParser(NewCRIParser(KlogRegex)), content)
+
+	want := []struct {
+		log    string
+		stream string
+		offset int64
+	}{
+		{"first", "stderr", 0},
+		{"long line split in three" + "goroutine 1 [running]:", "stdout", int64(strings.Index(content, "2022-01-02T15:04:05.200000000Z"))},
+		{"cut off at the end", "stderr", int64(strings.Index(content, "2022-01-02T15:04:05.400000000Z"))},
+	}
+	if len(logs) != len(want) {
+		for _, log := range logs {
+			t.Logf("%q", log.Log)
+		}
+		t.Fatalf("got %d logs, want %d", len(logs), len(want))
+	}
+	for i, log := range logs {
+		if log.Log != want[i].log || log.Stream != want[i].stream || log.BundleOffset != want[i].offset {
+			t.Errorf("log %d = %q %s at %d, want %q %s at %d", i, log.Log, log.Stream, log.BundleOffset, want[i].log, want[i].stream, want[i].offset)
+		}
+	}
+	if want := time.Date(2022, time.January, 2, 15, 4, 5, 200000000, time.UTC); !logs[1].Timestamp.Equal(want) {
+		t.Errorf("joined log time = %s, want the time of the first part %s", logs[1].Timestamp, want)
+	}
+}
+
+func TestCRIInterleavedPartialLines(t *testing.T) {
+	content := "2022-01-02T15:04:05.100000000Z stdout P I0102 15:04:05.100000 1 a.go:1] out \n" +
+		"2022-01-02T15:04:05.100000001Z stderr P E0102 15:04:05.100000 1 a.go:2] err \n" +
+		"2022-01-02T15:04:05.100000002Z stdout F split\n" +
+		"2022-01-02T15:04:05.100000003Z stderr P split \n" +
+		"2022-01-02T15:04:05.100000004Z stderr F in three\n" +
+		"2022-01-02T15:04:05.200000000Z stderr P I0102 15:04:05.200000 1 a.go:3] err cut \n" +
+		"2022-01-02T15:04:05.200000001Z stdout P I0102 15:04:05.200000 1 a.go:4] out cut \n" +
+		"2022-01-02T15:04:05.200000002Z stderr P off"
+
+	logs := publishTestFile(t, NewKlogParser(NewCRIParser(KlogRegex)), content)
+
+	want := []struct {
+		log    string
+		stream string
+		offset int64
+	}{
+		{"out split", "stdout", 0},
+		{"err split in three", "stderr", int64(strings.Index(content, "2022-01-02T15:04:05.100000001Z"))},
+		{"err cut off", "stderr", int64(strings.Index(content, "2022-01-02T15:04:05.200000000Z"))},
+		{"out cut ", "stdout", int64(strings.Index(content, "2022-01-02T15:04:05.200000001Z"))},
+	}
+	if len(logs) != len(want) {
+		for _, log := range logs {
+			t.Logf("%q", log.Log)
+		}
+		t.Fatalf("got %d logs, want %d", len(logs), len(want))
+	}
+	for i, log := range logs {
+		if log.Log != want[i].log || log.Stream != want[i].stream || log.BundleOffset != want[i].offset {
+			t.Errorf("log %d = %q %s at %d, want %q %s at %d", i, log.Log, log.Stream, log.BundleOffset, want[i].log, want[i].stream, want[i].offset)
+		}
+	}
+}
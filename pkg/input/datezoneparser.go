@@ -1,58 +1,93 @@
 package input
 
 import (
-	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
-	"github.com/dbason/opni-supportagent/pkg/util"
+	"github.com/dbason/opni-supportagent/pkg/errors"
 )
 
+const (
+	// captureSlack is how long after the bundle was captured a log can be
+	// dated, as logs are still written while the bundle is collected.
+	captureSlack = 24 * time.Hour
+	// maxYearsBack is how many years before the capture a timestamp is
+	// placed at most, enough to always reach a leap year for 29 February.
+	maxYearsBack = 8
+)
+
+// DateZoneParser parses logs whose timestamps have no year or timezone.  The
+// timestamps are taken to be in the timezone of the bundle, in the latest
+// year that doesn't put them after the bundle was captured, so logs from
+// December in a bundle captured in January are dated the year before.
 type DateZoneParser struct {
-	datetimeRegex string
-	layout        string
-	timezone      string
-	year          string
+	datetime *timestampMatcher
+	klog     *timestampMatcher
+	layout   string
+	captured time.Time
+	// isKlog is set if the timestamp is the klog timestamp, otherwise it is
+	// a prefix that must be followed by a klog timestamp.
+	isKlog bool
 }
 
-func NewDateZoneParser(timezone string, year string, datetimeRegex string, layout string) *DateZoneParser {
-	if timezone == "" {
-		timezone = "UTC"
-	}
-	if year == "" {
-		year = fmt.Sprint((time.Now().Year()))
+// NewDateZoneParser returns a parser for timestamps without a year or
+// timezone.  captured is when the bundle was captured, in the bundle's
+// timezone.  If it is zero the current time in UTC is used.
+func NewDateZoneParser(captured time.Time, datetimeRegex string, layout string) *DateZoneParser {
+	if captured.IsZero() {
+		captured = time.Now().UTC()
 	}
 	return &DateZoneParser{
-		datetimeRegex: datetimeRegex,
-		layout:        layout,
-		timezone:      timezone,
-		year:          year,
+		datetime: newTimestampMatcher(datetimeRegex),
+		klog:     newTimestampMatcher(KlogRegex),
+		layout:   layout,
+		captured: captured,
+		isKlog:   datetimeRegex == KlogRegex,
 	}
 }
 
-func (d *DateZoneParser) ParseTimestamp(log string) (time.Time, string, bool) {
-	re := regexp.MustCompile(d.datetimeRegex)
-	datestring := re.FindString(log)
-	if len(datestring) == 0 {
-		return time.Now(), log, false
+func (d *DateZoneParser) ParseTimestamp(log string) (time.Time, string, bool, error) {
+	start, end := d.datetime.find(log)
+	if start < 0 {
+		return time.Now(), log, false, nil
 	}
-	datetime, err := time.Parse(d.layout, fmt.Sprintf("%s %s %s", datestring, d.timezone, d.year))
+	datestring := log[start:end]
+	datetime, err := parseBeforeCapture(d.layout, datestring, d.captured)
 	if err != nil {
-		util.Log.Panic(err)
+		return time.Time{}, log, false, errors.ErrInvalidTimestampWithValue(datestring, err.Error())
 	}
 
 	retLog := log
 	valid := true
 
-	if d.datetimeRegex != KlogRegex {
-		cleaned := strings.TrimSpace(re.ReplaceAllString(log, ""))
+	if !d.isKlog {
+		cleaned := strings.TrimSpace(log[:start] + log[end:])
 		retLog = cleaned
+		valid = d.klog.findString(cleaned) != ""
+	}
 
-		re = regexp.MustCompile(KlogRegex)
-		datestring = re.FindString(cleaned)
-		valid = len(datestring) > 0
+	return datetime, retLog, valid, nil
+}
+
+// parseBeforeCapture parses a timestamp without a year or timezone.  The
+// timestamp is put in the timezone of captured, walking back a year at a
+// time from the year of captured until it is no later than captured.
+func parseBeforeCapture(layout string, datestring string, captured time.Time) (time.Time, error) {
+	parsed, err := time.Parse(layout, datestring)
+	if err != nil {
+		return time.Time{}, err
 	}
 
-	return datetime, retLog, valid
+	var datetime time.Time
+	for year := captured.Year(); year > captured.Year()-maxYearsBack; year-- {
+		datetime = time.Date(year, parsed.Month(), parsed.Day(), parsed.Hour(), parsed.Minute(), parsed.Second(), parsed.Nanosecond(), captured.Location())
+		// 29 February only exists in leap years
+		if datetime.Day() != parsed.Day() {
+			continue
+		}
+		if datetime.Sub(captured) <= captureSlack {
+			break
+		}
+	}
+	return datetime, nil
 }
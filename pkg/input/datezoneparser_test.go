@@ -0,0 +1,120 @@
+package input
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBeforeCapture(t *testing.T) {
+	chicago := time.FixedZone("CST", -6*60*60)
+	tests := []struct {
+		name       string
+		layout     string
+		datestring string
+		captured   time.Time
+		want       time.Time
+	}{
+		{
+			name:       "same year",
+			layout:     JournaldLayout,
+			datestring: "Jan  2 10:00:00",
+			captured:   time.Date(2022, time.January, 2, 16, 0, 0, 0, time.UTC),
+			want:       time.Date(2022, time.January, 2, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "december in a january bundle",
+			layout:     JournaldLayout,
+			datestring: "Dec 31 23:59:59",
+			captured:   time.Date(2022, time.January, 2, 16, 0, 0, 0, time.UTC),
+			want:       time.Date(2021, time.December, 31, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			name:       "written while the bundle was collected",
+			layout:     JournaldLayout,
+			datestring: "Jan  2 17:00:00",
+			captured:   time.Date(2022, time.January, 2, 16, 0, 0, 0, time.UTC),
+			want:       time.Date(2022, time.January, 2, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "leap day",
+			layout:     KlogLayout,
+			datestring: "0229 12:00:00.5",
+			captured:   time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC),
+			want:       time.Date(2020, time.February, 29, 12, 0, 0, 500000000, time.UTC),
+		},
+		{
+			name:       "timezone of the bundle",
+			layout:     JournaldLayout,
+			datestring: "Dec 31 22:00:00",
+			captured:   time.Date(2022, time.January, 1, 1, 0, 0, 0, chicago),
+			want:       time.Date(2022, time.January, 1, 4, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBeforeCapture(tt.layout, tt.datestring, tt.captured)
+			if err != nil {
+				t.Fatalf("parseBeforeCapture(%q) error: %s", tt.datestring, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseBeforeCapture(%q) = %s, want %s", tt.datestring, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseBeforeCapture(JournaldLayout, "Foo 31 22:00:00", time.Now()); err == nil {
+		t.Error("parseBeforeCapture of an invalid month error = nil, want an error")
+	}
+}
+
+func TestDateZoneParserNewYear(t *testing.T) {
+	captured := time.Date(2022, time.January, 1, 0, 30, 0, 0, time.UTC)
+	parser := NewDateZoneParser(captured, KlogRegex, KlogLayout)
+
+	lines := []string{
+		"I1231 23:59:59.900000       1 a.go:1] before new year",
+		"I0101 00:00:00.100000       1 a.go:1] after new year",
+	}
+	want := []time.Time{
+		time.Date(2021, time.December, 31, 23, 59, 59, 900000000, time.UTC),
+		time.Date(2022, time.January, 1, 0, 0, 0, 100000000, time.UTC),
+	}
+	for i, line := range lines {
+		datetime, message, valid, err := parser.ParseTimestamp(line)
+		if err != nil || !valid {
+			t.Fatalf("ParseTimestamp(%q) = %t, %v", line, valid, err)
+		}
+		if message != line {
+			t.Errorf("ParseTimestamp(%q) message = %q, want the line", line, message)
+		}
+		if !datetime.Equal(want[i]) {
+			t.Errorf("ParseTimestamp(%q) = %s, want %s", line, datetime, want[i])
+		}
+	}
+}
+
+func TestDateZoneParserPrefix(t *testing.T) {
+	captured := time.Date(2022, time.January, 2, 16, 0, 0, 0, time.UTC)
+	parser := NewDateZoneParser(captured, JournaldRegex, JournaldLayout)
+
+	// A timestamp before the log only starts a log if the log has a klog
+	// timestamp
+	tests := []struct {
+		line    string
+		message string
+		valid   bool
+	}{
+		{"Jan 02 15:04:05 node1 k3s[1]: I0102 15:04:05.123456 1 a.go:1] x", "node1 k3s[1]: I0102 15:04:05.123456 1 a.go:1] x", true},
+		{"Jan 02 15:04:05 node1 k3s[1]: goroutine 1 [running]:", "node1 k3s[1]: goroutine 1 [running]:", false},
+		{"  continued", "  continued", false},
+	}
+	for _, tt := range tests {
+		_, message, valid, err := parser.ParseTimestamp(tt.line)
+		if err != nil {
+			t.Fatalf("ParseTimestamp(%q) error: %s", tt.line, err)
+		}
+		if message != tt.message || valid != tt.valid {
+			t.Errorf("ParseTimestamp(%q) = %q, %t, want %q, %t", tt.line, message, valid, tt.message, tt.valid)
+		}
+	}
+}
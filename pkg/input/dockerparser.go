@@ -0,0 +1,215 @@
+package input
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+)
+
+const (
+	// innerTimestampTolerance is how far the timestamp in the log can be from
+	// the Docker timestamp and still be used as the time of the log.  Logs
+	// written in a different timezone are further out than this.
+	innerTimestampTolerance = time.Minute
+)
+
+// innerLayouts are the layouts of the timestamps in the logs that can be
+// more precise than the Docker timestamp.  Klog timestamps have no year, which
+// is taken from the Docker timestamp.
+var innerLayouts = map[string]string{
+	KlogRegex: KlogLayout,
+	EtcdRegex: "2006-01-02 15:04:05.999999",
+}
+
+// DockerParser parses container logs written by Docker, either by the
+// json-file log driver or by docker logs -t, which starts each line with an
+// RFC3339 timestamp.  The format is worked out from each line so either can
+// be read.  A line starts a new log if the log matches one of the timestamp
+// regexes.  The Docker timestamp is the time of the log unless the timestamp
+// in the log is more precise.
+type DockerParser struct {
+	timestamps []dockerTimestamp
+
+	// The last JSON line decoded is kept as each line is looked at more than
+	// once.
+	last      string
+	lastEntry dockerEntry
+	lastErr   error
+}
+
+type dockerTimestamp struct {
+	matcher *timestampMatcher
+	layout  string
+}
+
+// dockerEntry is a line written by the json-file log driver.
+type dockerEntry struct {
+	Log    string `json:"log"`
+	Stream string `json:"stream"`
+	Time   string `json:"time"`
+}
+
+func NewDockerParser(timestampRegexes ...string) *DockerParser {
+	parser := &DockerParser{}
+	for _, timestampRegex := range timestampRegexes {
+		parser.timestamps = append(parser.timestamps, dockerTimestamp{
+			matcher: newTimestampMatcher(timestampRegex),
+			layout:  innerLayouts[timestampRegex],
+		})
+	}
+	return parser
+}
+
+func isDockerJSON(line string) bool {
+	return strings.HasPrefix(line, "{")
+}
+
+func (p *DockerParser) decode(line string) (dockerEntry, error) {
+	if line != p.last {
+		p.last = line
+		p.lastEntry = dockerEntry{}
+		p.lastErr = json.Unmarshal([]byte(line), &p.lastEntry)
+	}
+	return p.lastEntry, p.lastErr
+}
+
+// split returns the Docker timestamp and the log of the line.
+func (p *DockerParser) split(line string) (string, string, error) {
+	if isDockerJSON(line) {
+		entry, err := p.decode(line)
+		if err != nil {
+			return "", line, errors.ErrInvalidTimestampWithValue("", err.Error())
+		}
+		return entry.Time, entry.Log, nil
+	}
+
+	i := strings.IndexByte(line, ' ')
+	if i < 0 {
+		return line, "", nil
+	}
+	return line[:i], line[i+1:], nil
+}
+
+func (p *DockerParser) ParseTimestamp(log string) (time.Time, string, bool, error) {
+	datestring, message, err := p.split(log)
+	if err != nil {
+		return time.Time{}, log, false, err
+	}
+	datetime, err := time.Parse(time.RFC3339Nano, datestring)
+	if err != nil {
+		return time.Time{}, log, false, errors.ErrInvalidTimestampWithValue(datestring, err.Error())
+	}
+	cleaned := strings.TrimSpace(message)
+
+	for _, timestamp := range p.timestamps {
+		start, end := timestamp.matcher.find(cleaned)
+		if start < 0 {
+			continue
+		}
+		if inner, ok := timestamp.parse(cleaned[start:end], datetime); ok &&
+			fractionDigits(cleaned[start:end]) > fractionDigits(datestring) {
+			datetime = inner
+		}
+		return datetime, cleaned, true, nil
+	}
+	return datetime, cleaned, false, nil
+}
+
+// parse returns the time of the timestamp found in the log, filling in the
+// year from the Docker timestamp if it is missing.  False is returned if the
+// timestamp can't be used.
+func (t dockerTimestamp) parse(datestring string, docker time.Time) (time.Time, bool) {
+	if t.layout == "" {
+		return time.Time{}, false
+	}
+	datetime, err := time.Parse(t.layout, datestring)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if datetime.Year() == 0 {
+		datetime = datetime.AddDate(docker.Year(), 0, 0)
+		// The log may have been written either side of new year
+		switch {
+		case datetime.Sub(docker) > 183*24*time.Hour:
+			datetime = datetime.AddDate(-1, 0, 0)
+		case docker.Sub(datetime) > 183*24*time.Hour:
+			datetime = datetime.AddDate(1, 0, 0)
+		}
+	}
+
+	difference := datetime.Sub(docker)
+	if difference < -innerTimestampTolerance || difference > innerTimestampTolerance {
+		return time.Time{}, false
+	}
+	return datetime, true
+}
+
+// fractionDigits returns the number of digits of the fraction of a second in
+// the timestamp.
+func fractionDigits(datestring string) int {
+	i := strings.IndexByte(datestring, '.')
+	if i < 0 {
+		return 0
+	}
+	n := 0
+	for _, c := range []byte(datestring[i+1:]) {
+		if !isDigit(c) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// Partial returns whether the line is part of a longer log line.  The
+// json-file log driver splits long lines into parts, only the last of which
+// ends with a newline.
+func (p *DockerParser) Partial(line string) bool {
+	if !isDockerJSON(line) {
+		return false
+	}
+	entry, err := p.decode(line)
+	return err == nil && !strings.HasSuffix(entry.Log, "\n")
+}
+
+func (p *DockerParser) Stream(line string) string {
+	if !isDockerJSON(line) {
+		return ""
+	}
+	entry, _ := p.decode(line)
+	return entry.Stream
+}
+
+func (p *DockerParser) Join(partials []string, line string) string {
+	if len(partials) == 0 {
+		return line
+	}
+	// Keep the time and stream of the first part
+	joined, _ := p.decode(partials[0])
+	var b strings.Builder
+	for _, partial := range partials {
+		entry, _ := p.decode(partial)
+		b.WriteString(entry.Log)
+	}
+	if entry, err := p.decode(line); err == nil {
+		b.WriteString(entry.Log)
+	}
+	joined.Log = b.String()
+
+	data, err := json.Marshal(joined)
+	if err != nil {
+		return line
+	}
+	return string(data)
+}
+
+func (p *DockerParser) Annotate(line string, log *LogMessage) {
+	if !isDockerJSON(line) {
+		return
+	}
+	if entry, err := p.decode(line); err == nil {
+		log.Stream = entry.Stream
+	}
+}
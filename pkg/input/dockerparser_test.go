@@ -0,0 +1,152 @@
+package input
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDockerParserParseTimestamp(t *testing.T) {
+	docker := time.Date(2022, time.January, 2, 15, 4, 5, 100000000, time.UTC)
+	tests := []struct {
+		name    string
+		parser  *DockerParser
+		line    string
+		time    time.Time
+		message string
+		valid   bool
+	}{
+		{
+			name:    "json-file klog",
+			parser:  NewDockerParser(KlogRegex),
+			line:    `{"log":"I0102 15:04:05.123456       1 controller.go:611] synced\n","stream":"stderr","time":"2022-01-02T15:04:05.1Z"}`,
+			time:    time.Date(2022, time.January, 2, 15, 4, 5, 123456000, time.UTC),
+			message: "I0102 15:04:05.123456       1 controller.go:611] synced",
+			valid:   true,
+		},
+		{
+			name:    "docker logs -t klog",
+			parser:  NewDockerParser(KlogRegex),
+			line:    `2022-01-02T15:04:05.1Z I0102 15:04:05.123456       1 controller.go:611] synced`,
+			time:    time.Date(2022, time.January, 2, 15, 4, 5, 123456000, time.UTC),
+			message: "I0102 15:04:05.123456       1 controller.go:611] synced",
+			valid:   true,
+		},
+		{
+			name:    "more precise Docker timestamp",
+			parser:  NewDockerParser(KlogRegex),
+			line:    `{"log":"I0102 15:04:05.123456       1 controller.go:611] synced\n","stream":"stderr","time":"2022-01-02T15:04:05.100000001Z"}`,
+			time:    time.Date(2022, time.January, 2, 15, 4, 5, 100000001, time.UTC),
+			message: "I0102 15:04:05.123456       1 controller.go:611] synced",
+			valid:   true,
+		},
+		{
+			name:    "log in another timezone",
+			parser:  NewDockerParser(KlogRegex),
+			line:    `{"log":"I0102 21:04:05.123456       1 controller.go:611] synced\n","stream":"stderr","time":"2022-01-02T15:04:05.1Z"}`,
+			time:    docker,
+			message: "I0102 21:04:05.123456       1 controller.go:611] synced",
+			valid:   true,
+		},
+		{
+			name:    "klog across new year",
+			parser:  NewDockerParser(KlogRegex),
+			line:    `{"log":"I1231 23:59:59.999999       1 controller.go:611] synced\n","stream":"stderr","time":"2022-01-01T00:00:00.0Z"}`,
+			time:    time.Date(2021, time.December, 31, 23, 59, 59, 999999000, time.UTC),
+			message: "I1231 23:59:59.999999       1 controller.go:611] synced",
+			valid:   true,
+		},
+		{
+			name:    "etcd",
+			parser:  NewDockerParser(EtcdRegex, EtcdJSONRegex),
+			line:    `{"log":"2022-01-02 15:04:05.123456 I | etcdserver: published\n","stream":"stderr","time":"2022-01-02T15:04:05.1Z"}`,
+			time:    time.Date(2022, time.January, 2, 15, 4, 5, 123456000, time.UTC),
+			message: "2022-01-02 15:04:05.123456 I | etcdserver: published",
+			valid:   true,
+		},
+		{
+			name:    "etcd JSON",
+			parser:  NewDockerParser(EtcdRegex, EtcdJSONRegex),
+			line:    `{"log":"{\"level\":\"warn\",\"msg\":\"slow\"}\n","stream":"stderr","time":"2022-01-02T15:04:05.1Z"}`,
+			time:    docker,
+			message: `{"level":"warn","msg":"slow"}`,
+			valid:   true,
+		},
+		{
+			name:    "continuation",
+			parser:  NewDockerParser(KlogRegex),
+			line:    `{"log":"\tgoroutine 1 [running]:\n","stream":"stderr","time":"2022-01-02T15:04:05.1Z"}`,
+			time:    docker,
+			message: "goroutine 1 [running]:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			datetime, message, valid, err := tt.parser.ParseTimestamp(tt.line)
+			if err != nil {
+				t.Fatalf("ParseTimestamp(%q) error: %s", tt.line, err)
+			}
+			if !datetime.Equal(tt.time) || message != tt.message || valid != tt.valid {
+				t.Errorf("ParseTimestamp(%q) = %s, %q, %t, want %s, %q, %t", tt.line, datetime, message, valid, tt.time, tt.message, tt.valid)
+			}
+		})
+	}
+}
+
+func TestDockerParserInvalid(t *testing.T) {
+	parser := NewDockerParser(KlogRegex)
+	for _, line := range []string{
+		`{"log":"truncated`,
+		`{"log":"no time\n","stream":"stderr"}`,
+		`yesterday I0102 15:04:05.123456 1 a.go:1] synced`,
+	} {
+		if _, _, _, err := parser.ParseTimestamp(line); err == nil {
+			t.Errorf("ParseTimestamp(%q) error = nil, want an error", line)
+		}
+	}
+}
+
+func TestDockerPartialLines(t *testing.T) {
+	content := `{"log":"I0102 15:04:05.100000 1 a.go:1] first\n","stream":"stderr","time":"2022-01-02T15:04:05.1Z"}` + "\n" +
+		`{"log":"I0102 15:04:05.200000 1 a.go:2] long ","stream":"stdout","time":"2022-01-02T15:04:05.2Z"}` + "\n" +
+		`{"log":"line split ","stream":"stdout","time":"2022-01-02T15:04:05.21Z"}` + "\n" +
+		`{"log":"in three\n","stream":"stdout","time":"2022-01-02T15:04:05.22Z"}` + "\n" +
+		`2022-01-02T15:04:05.3Z I0102 15:04:05.300000 1 a.go:3] text format` + "\n"
+
+	logs := publishTestFile(t, NewKlogParser(NewDockerParser(KlogRegex)), content)
+
+	want := []struct {
+		log    string
+		stream string
+		offset int64
+	}{
+		{"first", "stderr", 0},
+		{"long line split in three", "stdout", int64(strings.Index(content, `{"log":"I0102 15:04:05.200000`))},
+		{"text format", "", int64(strings.Index(content, "2022-01-02T15:04:05.3Z"))},
+	}
+	if len(logs) != len(want) {
+		for _, log := range logs {
+			t.Logf("%q", log.Log)
+		}
+		t.Fatalf("got %d logs, want %d", len(logs), len(want))
+	}
+	for i, log := range logs {
+		if log.Log != want[i].log || log.Stream != want[i].stream || log.BundleOffset != want[i].offset {
+			t.Errorf("log %d = %q %s at %d, want %q %s at %d", i, log.Log, log.Stream, log.BundleOffset, want[i].log, want[i].stream, want[i].offset)
+		}
+	}
+}
+
+func TestFractionDigits(t *testing.T) {
+	tests := map[string]int{
+		"2022-01-02T15:04:05Z":           0,
+		"2022-01-02T15:04:05.1Z":         1,
+		"2022-01-02T15:04:05.123456789Z": 9,
+		"0102 15:04:05.123456":           6,
+	}
+	for datestring, want := range tests {
+		if got := fractionDigits(datestring); got != want {
+			t.Errorf("fractionDigits(%q) = %d, want %d", datestring, got, want)
+		}
+	}
+}
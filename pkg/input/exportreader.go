@@ -0,0 +1,218 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+)
+
+// exportReader reads the entries of the journald export format written by
+// journalctl -o export, and returns each as a line of the JSON output so it
+// is parsed the same way.  Fields are written as NAME=value and a newline, or
+// if the value isn't text as NAME and a newline, the length of the value as
+// a little endian 64 bit integer, the value and a newline.  An empty line
+// ends each entry.  Values are read by their length as they can hold any
+// bytes, including newlines.
+type exportReader struct {
+	reader  *bufio.Reader
+	maxSize int
+
+	line []byte
+	// dropped is the number of bytes cut from the message.  Other fields are
+	// cut to the maximum size too but aren't counted, as they aren't part of
+	// the log.
+	dropped int
+	// offset is where the next entry starts, lineOffset is where the current
+	// entry starts.
+	offset     int64
+	lineOffset int64
+	err        error
+}
+
+func newExportReader(reader *bufio.Reader, maxSize int) *exportReader {
+	return &exportReader{
+		reader:  reader,
+		maxSize: maxSize,
+	}
+}
+
+// Scan reads the next entry, returning false at the end of the file or if
+// the read failed.
+func (e *exportReader) Scan() bool {
+	if e.err != nil {
+		return false
+	}
+	e.lineOffset = e.offset
+	e.dropped = 0
+
+	var entry map[string]interface{}
+	for {
+		field, dropped, err := e.readLine()
+		if err == io.EOF && len(field) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			e.err = err
+			return false
+		}
+		// An empty line ends the entry
+		if len(field) == 0 && dropped == 0 {
+			if entry == nil {
+				continue
+			}
+			break
+		}
+		if entry == nil {
+			entry = map[string]interface{}{}
+		}
+
+		name, value := field, []byte(nil)
+		if equals := bytes.IndexByte(field, '='); equals >= 0 {
+			name, value = field[:equals], field[equals+1:]
+		} else {
+			value, dropped, err = e.readBinary()
+			if err != nil {
+				e.err = err
+				return false
+			}
+		}
+		// Fields that appear more than once keep their first value, as the
+		// JSON output is read
+		if _, ok := entry[string(name)]; !ok {
+			entry[string(name)] = exportValue(value)
+			if string(name) == journaldMessage {
+				e.dropped = dropped
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if entry == nil {
+		return false
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		e.err = err
+		return false
+	}
+	e.line = line
+	return true
+}
+
+// readLine reads a field name or text field up to the next newline, cut to
+// the maximum size, and returns it with the number of bytes cut.
+func (e *exportReader) readLine() ([]byte, int, error) {
+	var line []byte
+	length := 0
+	for {
+		chunk, err := e.reader.ReadSlice('\n')
+		e.offset += int64(len(chunk))
+		length += len(chunk)
+		if room := e.maxSize - len(line); room > 0 {
+			if len(chunk) < room {
+				room = len(chunk)
+			}
+			line = append(line, chunk[:room]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == nil {
+			length--
+			if len(line) > length {
+				line = line[:length]
+			}
+		}
+		if length > len(line) {
+			line = trimPartialRune(line)
+		}
+		return line, length - len(line), err
+	}
+}
+
+// readBinary reads the length and value of a field that isn't text, cut to
+// the maximum size, and returns the value with the number of bytes cut.
+func (e *exportReader) readBinary() ([]byte, int, error) {
+	var size [8]byte
+	n, err := io.ReadFull(e.reader, size[:])
+	e.offset += int64(n)
+	if err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+	length := binary.LittleEndian.Uint64(size[:])
+
+	kept := length
+	if kept > uint64(e.maxSize) {
+		kept = uint64(e.maxSize)
+	}
+	value := make([]byte, kept)
+	n, err = io.ReadFull(e.reader, value)
+	e.offset += int64(n)
+	if err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+	discarded, err := e.reader.Discard(int(length - kept))
+	e.offset += int64(discarded)
+	if err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+
+	// The value is followed by a newline
+	newline, err := e.reader.ReadByte()
+	if err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+	e.offset++
+	if newline != '\n' {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	if kept < length {
+		value = trimPartialRune(value)
+	}
+	return value, int(length) - len(value), nil
+}
+
+// exportValue returns the value of a field as journalctl -o json would write
+// it, as a string if it is valid UTF-8 and otherwise as an array of bytes.
+func exportValue(value []byte) interface{} {
+	if utf8.Valid(value) {
+		return string(value)
+	}
+	numbers := make([]int, len(value))
+	for i, b := range value {
+		numbers[i] = int(b)
+	}
+	return numbers
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF if the file ended part way
+// through a field.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (e *exportReader) Text() string {
+	return string(e.line)
+}
+
+// Dropped returns the number of bytes cut from the end of the message.
+func (e *exportReader) Dropped() int {
+	return e.dropped
+}
+
+// LineOffset returns where the entry starts in the file.
+func (e *exportReader) LineOffset() int64 {
+	return e.lineOffset
+}
+
+func (e *exportReader) Err() error {
+	return e.err
+}
@@ -0,0 +1,168 @@
+package input
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exportField returns a field of the export format with a binary value.
+func exportField(name string, value string) string {
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	return name + "\n" + string(size[:]) + value + "\n"
+}
+
+func readExport(t *testing.T, export string, maxSize int) ([]map[string]interface{}, []int64, []int) {
+	t.Helper()
+	reader := newLogReader(strings.NewReader(export), maxSize)
+	entryReader, ok := reader.(*exportReader)
+	if !ok {
+		t.Fatalf("newLogReader returned %T, want *exportReader", reader)
+	}
+	var entries []map[string]interface{}
+	var offsets []int64
+	var dropped []int
+	for reader.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(reader.Text()), &entry); err != nil {
+			t.Fatalf("entry %q isn't JSON: %s", reader.Text(), err)
+		}
+		entries = append(entries, entry)
+		offsets = append(offsets, reader.LineOffset())
+		dropped = append(dropped, reader.Dropped())
+	}
+	if err := reader.Err(); err != nil {
+		t.Fatalf("Err() = %s", err)
+	}
+	if entryReader.offset != int64(len(export)) {
+		t.Errorf("offset = %d, want %d", entryReader.offset, len(export))
+	}
+	return entries, offsets, dropped
+}
+
+func TestExportReaderBinaryFields(t *testing.T) {
+	// The message is 10 bytes so its length starts with a newline, and it
+	// holds a blank line and a carriage return
+	message := "a\r\n\nb\nc\r\nd"
+	first := "__CURSOR=s=1\n" +
+		"__REALTIME_TIMESTAMP=1641135845123456\n" +
+		exportField("MESSAGE", message) +
+		"_PID=856\n" +
+		"\n"
+	second := "__CURSOR=s=2\n" +
+		"__REALTIME_TIMESTAMP=1641135846000000\n" +
+		exportField("MESSAGE", "bad \xff byte") +
+		"MESSAGE=repeated\n" +
+		"_HOSTNAME=node1\r\n" +
+		"\n"
+	entries, offsets, _ := readExport(t, first+second, DefaultMaxLineSize)
+
+	want := []map[string]interface{}{
+		{
+			"__CURSOR":             "s=1",
+			"__REALTIME_TIMESTAMP": "1641135845123456",
+			"MESSAGE":              message,
+			"_PID":                 "856",
+		},
+		{
+			"__CURSOR":             "s=2",
+			"__REALTIME_TIMESTAMP": "1641135846000000",
+			"MESSAGE":              []interface{}{98.0, 97.0, 100.0, 32.0, 255.0, 32.0, 98.0, 121.0, 116.0, 101.0},
+			"_HOSTNAME":            "node1\r",
+		},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %v, want %v", entries, want)
+	}
+	if wantOffsets := []int64{0, int64(len(first))}; !reflect.DeepEqual(offsets, wantOffsets) {
+		t.Errorf("offsets = %v, want %v", offsets, wantOffsets)
+	}
+}
+
+func TestExportReaderTruncates(t *testing.T) {
+	export := "__CURSOR=s=1\n" +
+		exportField("MESSAGE", strings.Repeat("x", 30)) +
+		"\n" +
+		"__CURSOR=s=2\n" +
+		"MESSAGE=" + strings.Repeat("y", 30) + "\n" +
+		"_COMM=" + strings.Repeat("z", 30) + "\n"
+	entries, _, dropped := readExport(t, export, 16)
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if message := entries[0]["MESSAGE"]; message != strings.Repeat("x", 16) {
+		t.Errorf("binary message = %q, want 16 bytes", message)
+	}
+	if message := entries[1]["MESSAGE"]; message != strings.Repeat("y", 8) {
+		t.Errorf("text message = %q, want the line cut to 16 bytes", message)
+	}
+	if !reflect.DeepEqual(dropped, []int{14, 22}) {
+		t.Errorf("dropped = %v, want [14 22]", dropped)
+	}
+}
+
+func TestExportReaderUnexpectedEOF(t *testing.T) {
+	export := "__CURSOR=s=1\n" + exportField("MESSAGE", "complete") + "\n"
+	export += "__CURSOR=s=2\n" + exportField("MESSAGE", "cut short")[:20]
+
+	reader := newLogReader(strings.NewReader(export), DefaultMaxLineSize)
+	entries := 0
+	for reader.Scan() {
+		entries++
+	}
+	if entries != 1 {
+		t.Errorf("read %d entries, want 1", entries)
+	}
+	if reader.Err() == nil {
+		t.Error("Err() = nil, want an error for the entry cut short")
+	}
+}
+
+func TestNewLogReaderText(t *testing.T) {
+	reader := newLogReader(bytes.NewReader([]byte("Jan 02 15:04:05 node1 k3s[1]: __CURSOR=\n")), DefaultMaxLineSize)
+	if _, ok := reader.(*lineReader); !ok {
+		t.Errorf("newLogReader returned %T, want *lineReader", reader)
+	}
+}
+
+func TestJournaldExport(t *testing.T) {
+	captured := time.Date(2022, time.January, 2, 16, 0, 0, 0, time.UTC)
+	message := "panic: boom\n\ngoroutine 1 [running]:\r\n"
+	export := "__CURSOR=s=1\n" +
+		"__REALTIME_TIMESTAMP=1641135845123456\n" +
+		"_SYSTEMD_UNIT=k3s.service\n" +
+		"SYSLOG_IDENTIFIER=k3s\n" +
+		"_PID=856\n" +
+		"PRIORITY=3\n" +
+		exportField("MESSAGE", message) +
+		"\n" +
+		"__CURSOR=s=2\n" +
+		"__REALTIME_TIMESTAMP=1641135846000000\n" +
+		"SYSLOG_IDENTIFIER=systemd\n" +
+		"_PID=1\n" +
+		"MESSAGE=Stopped k3s.\n" +
+		"\n"
+
+	logs := publishTestFile(t, NewKlogParser(NewJournaldParser(captured)), export)
+	if len(logs) != 2 {
+		t.Fatalf("got %d logs, want 2", len(logs))
+	}
+	if logs[0].Log != message {
+		t.Errorf("log = %q, want %q", logs[0].Log, message)
+	}
+	if want := time.UnixMicro(1641135845123456).UTC(); !logs[0].Timestamp.Equal(want) {
+		t.Errorf("timestamp = %s, want %s", logs[0].Timestamp, want)
+	}
+	if logs[0].Unit != "k3s.service" || logs[0].PID != 856 || logs[0].Priority == nil || *logs[0].Priority != 3 {
+		t.Errorf("fields = %s %d %v, want k3s.service 856 3", logs[0].Unit, logs[0].PID, logs[0].Priority)
+	}
+	if logs[1].Log != "Stopped k3s." || logs[1].Process != "systemd" || logs[1].BundleOffset == 0 {
+		t.Errorf("second log = %q from %s at %d", logs[1].Log, logs[1].Process, logs[1].BundleOffset)
+	}
+}
@@ -0,0 +1,319 @@
+package input
+
+import (
+	"context"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+	"github.com/dbason/opni-supportagent/pkg/util"
+)
+
+const (
+	defaultBatchSize = 500
+)
+
+// FileInput reads the log files of a component from the bundle and writes
+// them to a sink.
+type FileInput struct {
+	ctx       context.Context
+	config    FileConfig
+	sink      Sink
+	unparsed  UnparsedCounts
+	truncated uint64
+}
+
+type FileConfig struct {
+	// Bundle is the filesystem Paths are read from.
+	Bundle    fs.FS
+	ClusterID string
+	NodeName  string
+	Paths     []string
+	Component string
+	// BatchSize is the number of logs written to the sink at once.
+	BatchSize int
+	// Checkpoint, if set, tracks the logs stored by the sink and skips logs
+	// stored by a previous publish.
+	Checkpoint *Checkpoint
+	// Progress, if set, is told how much of the files has been read.
+	Progress ProgressReporter
+	// UnparsedPolicy is what is done with lines whose timestamp can't be
+	// parsed.  Defaults to attaching them to the previous log.
+	UnparsedPolicy UnparsedPolicy
+	// MaxLineSize is the longest line read, longer lines are truncated.
+	// Defaults to 1MiB.
+	MaxLineSize int
+	// Rotation names the rotation the files are from, it is added to each
+	// log.
+	Rotation string
+}
+
+func NewFileInput(ctx context.Context, sink Sink, config FileConfig) *FileInput {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.UnparsedPolicy == "" {
+		config.UnparsedPolicy = UnparsedAttach
+	}
+	if config.MaxLineSize <= 0 {
+		config.MaxLineSize = DefaultMaxLineSize
+	}
+	return &FileInput{
+		ctx:    ctx,
+		config: config,
+		sink:   sink,
+	}
+}
+
+// Unparsed returns what was done with the lines whose timestamp couldn't be
+// parsed.
+func (i *FileInput) Unparsed() UnparsedCounts {
+	return i.unparsed
+}
+
+// Truncated returns the number of lines that were longer than MaxLineSize.
+func (i *FileInput) Truncated() uint64 {
+	return i.truncated
+}
+
+// partialLine holds the parts of a line read so far.
+type partialLine struct {
+	lines   []string
+	offset  int64
+	dropped int
+}
+
+func (i *FileInput) Publish(parser DateParser, logType LogType) (time.Time, time.Time, error) {
+	var start, end time.Time
+	batch := make([]LogMessage, 0, i.config.BatchSize)
+
+	// file is the current file and reader reads its lines
+	var file *logFile
+	var reader logReader
+
+	// report tells Progress how far the file has been read since it was last
+	// told.
+	var reported int64
+	var parsed uint64
+	report := func() {
+		if i.config.Progress == nil {
+			return
+		}
+		i.config.Progress.Read(file.BytesRead()-reported, parsed)
+		reported = file.BytesRead()
+		parsed = 0
+	}
+
+	// add queues the log and writes the batch to the sink once it is full.
+	add := func(log LogMessage) error {
+		if i.config.Checkpoint != nil {
+			i.config.Checkpoint.Track(log)
+		}
+		parsed++
+		batch = append(batch, log)
+		if len(batch) < i.config.BatchSize {
+			return nil
+		}
+		// Stop reading once the publish has been cancelled
+		if err := i.ctx.Err(); err != nil {
+			return err
+		}
+		err := i.sink.Write(i.ctx, batch)
+		batch = batch[:0]
+		report()
+		return err
+	}
+
+	partialParser, _ := parser.(PartialLineParser)
+	annotator, _ := parser.(Annotator)
+
+	// readErr is the first file that couldn't be read to the end.  The logs
+	// read before the error are still published, as are the other files.
+	var readErr error
+
+	for _, path := range i.config.Paths {
+		// Read the file, decompressing it if it is gzipped
+		var err error
+		file, err = openLogFile(i.config.Bundle, path)
+		if err != nil {
+			return start, end, err
+		}
+		defer file.Close()
+
+		// previousLog is the log being read, hasPrevious is set once the
+		// first log in the file has been found
+		var previousLog LogMessage
+		var hasPrevious bool
+		var lastTimestamp time.Time
+
+		// process adds the line to the previous log, or starts a new log
+		// with it.  dropped is the number of bytes truncated from the line.
+		process := func(line string, lineOffset int64, dropped int) error {
+			datetime, log, valid, err := parser.ParseTimestamp(line)
+			if err != nil {
+				util.Log.Debugf("%s: %s", path, err)
+				switch {
+				case i.config.UnparsedPolicy == UnparsedAttach && hasPrevious:
+					i.unparsed.Attached++
+					previousLog.appendLine(line, dropped)
+				case i.config.UnparsedPolicy == UnparsedIndex && !lastTimestamp.IsZero():
+					i.unparsed.Indexed++
+					if hasPrevious {
+						if err := add(previousLog); err != nil {
+							return err
+						}
+					}
+					previousLog = LogMessage{
+						Time:         lastTimestamp,
+						Timestamp:    lastTimestamp,
+						Log:          line,
+						Agent:        "support",
+						LogType:      logType,
+						Component:    i.config.Component,
+						ClusterID:    i.config.ClusterID,
+						NodeName:     i.config.NodeName,
+						BundleFile:   path,
+						BundleOffset: lineOffset,
+						Rotation:     i.config.Rotation,
+						Unparsed:     true,
+					}
+					hasPrevious = true
+					previousLog.truncated(dropped)
+				default:
+					// Lines before the first log in the file have nothing to
+					// be attached to or take their timestamp from
+					i.unparsed.Skipped++
+				}
+				return nil
+			}
+
+			if !valid {
+				// if it's not a valid datetime add the log to the previous string
+				if hasPrevious {
+					previousLog.appendLine(log, dropped)
+				}
+				return nil
+			}
+
+			lastTimestamp = datetime
+			if start.IsZero() || datetime.Before(start) {
+				start = datetime
+			}
+
+			if end.IsZero() || datetime.After(end) {
+				end = datetime
+			}
+
+			if hasPrevious {
+				// Failing to write to the sink is unrecoverable
+				if err := add(previousLog); err != nil {
+					return err
+				}
+			}
+			previousLog = LogMessage{
+				Time:         datetime,
+				Timestamp:    datetime,
+				Log:          log,
+				Agent:        "support",
+				LogType:      logType,
+				Component:    i.config.Component,
+				ClusterID:    i.config.ClusterID,
+				NodeName:     i.config.NodeName,
+				BundleFile:   path,
+				BundleOffset: lineOffset,
+				Rotation:     i.config.Rotation,
+			}
+			hasPrevious = true
+			if annotator != nil {
+				annotator.Annotate(line, &previousLog)
+			}
+			previousLog.truncated(dropped)
+			return nil
+		}
+
+		// Lines split into partial lines are joined back together before
+		// they are processed, and take the offset of the first part.  The
+		// parts of each stream are kept apart as the streams can be
+		// interleaved.
+		partials := map[string]*partialLine{}
+
+		// The reader tracks the offset of each line so logs can be found in
+		// the bundle
+		reader = newLogReader(file, i.config.MaxLineSize)
+		reported = 0
+		for reader.Scan() {
+			line := reader.Text()
+			lineOffset := reader.LineOffset()
+			dropped := reader.Dropped()
+			if dropped > 0 {
+				util.Log.Debugf("%s: line at %d is %d bytes, truncating", path, lineOffset, len(line)+dropped)
+				i.truncated++
+			}
+
+			if i.config.Checkpoint != nil && i.config.Checkpoint.Stored(i.config.NodeName, path, lineOffset) {
+				continue
+			}
+
+			if partialParser != nil {
+				stream := partialParser.Stream(line)
+				if partialParser.Partial(line) {
+					partial, ok := partials[stream]
+					if !ok {
+						partial = &partialLine{offset: lineOffset}
+						partials[stream] = partial
+					}
+					partial.lines = append(partial.lines, line)
+					partial.dropped += dropped
+					continue
+				}
+				if partial, ok := partials[stream]; ok {
+					line = partialParser.Join(partial.lines, line)
+					lineOffset = partial.offset
+					dropped += partial.dropped
+					delete(partials, stream)
+				}
+			}
+
+			if err := process(line, lineOffset, dropped); err != nil {
+				return start, end, err
+			}
+		}
+		if err := reader.Err(); err != nil && readErr == nil {
+			readErr = errors.ErrUnreadableFileWithPath(path, err.Error())
+		}
+
+		// The file ended part way through a line, in the order the lines
+		// started
+		unfinished := make([]*partialLine, 0, len(partials))
+		for _, partial := range partials {
+			unfinished = append(unfinished, partial)
+		}
+		sort.Slice(unfinished, func(a, b int) bool {
+			return unfinished[a].offset < unfinished[b].offset
+		})
+		for _, partial := range unfinished {
+			last := len(partial.lines) - 1
+			line := partialParser.Join(partial.lines[:last], partial.lines[last])
+			if err := process(line, partial.offset, partial.dropped); err != nil {
+				return start, end, err
+			}
+		}
+
+		// The last log in the file has nothing following it to end it
+		if hasPrevious {
+			if err := add(previousLog); err != nil {
+				return start, end, err
+			}
+		}
+		report()
+	}
+
+	if len(batch) > 0 {
+		if err := i.sink.Write(i.ctx, batch); err != nil {
+			return start, end, err
+		}
+	}
+
+	return start, end, readErr
+}
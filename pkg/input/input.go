@@ -1,16 +1,24 @@
 package input
 
-import "time"
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
 
 const (
 	KlogRegex     = `\d{4} \d{2}:\d{2}:\d{2}.\d{6}`
 	EtcdRegex     = `^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}.\d{6}`
 	RancherRegex  = `^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}`
-	JournaldRegex = `^[A-Z][a-z]{2} \d{1,2} \d{2}:\d{2}:\d{2}`
+	JournaldRegex = `^[A-Z][a-z]{2} {1,2}\d{1,2} \d{2}:\d{2}:\d{2}`
 
+	// The klog and journald layouts have no year or timezone, which are
+	// filled in from when the bundle was captured.
 	RancherLayout  = "2006/01/02 15:05:05"
-	KlogLayout     = "0102 15:04:05.999999 MST 2006"
-	JournaldLayout = "Jan 02 15:04:05 MST 2006"
+	KlogLayout     = "0102 15:04:05.999999"
+	JournaldLayout = "Jan _2 15:04:05"
 )
 
 type LogType string
@@ -20,6 +28,48 @@ const (
 	LogTypeRancher      LogType = "rancher"
 )
 
+// UnparsedPolicy is what is done with a line whose timestamp can't be
+// parsed.
+type UnparsedPolicy string
+
+const (
+	// UnparsedSkip drops the line.
+	UnparsedSkip UnparsedPolicy = "skip"
+	// UnparsedAttach adds the line to the previous log, the same as a line
+	// without a timestamp.
+	UnparsedAttach UnparsedPolicy = "attach"
+	// UnparsedIndex publishes the line as its own log with the last timestamp
+	// read from the file and marks it as unparsed.
+	UnparsedIndex UnparsedPolicy = "index"
+)
+
+func (p UnparsedPolicy) Valid() bool {
+	switch p {
+	case UnparsedSkip, UnparsedAttach, UnparsedIndex:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnparsedCounts counts what was done with the lines whose timestamp couldn't
+// be parsed.
+type UnparsedCounts struct {
+	Skipped  uint64
+	Attached uint64
+	Indexed  uint64
+}
+
+func (c UnparsedCounts) Total() uint64 {
+	return c.Skipped + c.Attached + c.Indexed
+}
+
+func (c *UnparsedCounts) Add(other UnparsedCounts) {
+	c.Skipped += other.Skipped
+	c.Attached += other.Attached
+	c.Indexed += other.Indexed
+}
+
 type LogMessage struct {
 	Timestamp time.Time `json:"timestamp,omitempty"`
 	Time      time.Time `json:"time,omitempty"`
@@ -29,13 +79,127 @@ type LogMessage struct {
 	Component string    `json:"kubernetes_component,omitempty"`
 	ClusterID string    `json:"cluster_id,omitempty"`
 	NodeName  string    `json:"node_name,omitempty"`
+	// BundleFile and BundleOffset record where in the bundle the log was read
+	// from.
+	BundleFile   string `json:"bundle_file,omitempty"`
+	BundleOffset int64  `json:"bundle_offset"`
+	// Rotation is the rotation of the log file the log was read from,
+	// current for the file being written to when the bundle was collected.
+	Rotation string `json:"rotation,omitempty"`
+	// Stream is the output, stdout or stderr, a container wrote the log to.
+	Stream string `json:"stream,omitempty"`
+	// The journald fields of the log.  Logs from the text output of
+	// journalctl only have the hostname, process and PID.  Priority is the
+	// syslog priority, 0 for emerg to 7 for debug.
+	Unit     string `json:"systemd_unit,omitempty"`
+	Process  string `json:"process,omitempty"`
+	PID      int    `json:"pid,omitempty"`
+	Priority *int   `json:"priority,omitempty"`
+	BootID   string `json:"boot_id,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	// The fields of the klog header of the log.  ThreadID is kept apart from
+	// PID as a klog log read from journald has both.  SourceFile and
+	// SourceLine are where the log was written in the code.
+	Level      string `json:"level,omitempty"`
+	ThreadID   int    `json:"thread_id,omitempty"`
+	SourceFile string `json:"source_file,omitempty"`
+	SourceLine int    `json:"source_line,omitempty"`
+	// Fields are the key value pairs of a structured klog log.  The
+	// Kubernetes objects the log is about are also copied from them, the pod
+	// and object as namespace/name.
+	Fields    map[string]string `json:"fields,omitempty"`
+	Pod       string            `json:"kubernetes_pod,omitempty"`
+	Node      string            `json:"kubernetes_node,omitempty"`
+	Namespace string            `json:"kubernetes_namespace,omitempty"`
+	Object    string            `json:"kubernetes_object,omitempty"`
+	// Unparsed is set when the timestamp of the log couldn't be parsed and
+	// the last timestamp before it was used instead.
+	Unparsed bool `json:"unparsed,omitempty"`
+	// Truncated is set when a line of the log was too long to be read in
+	// full.  OriginalLength is then the length the log would have had.
+	Truncated      bool `json:"truncated,omitempty"`
+	OriginalLength int  `json:"original_length,omitempty"`
+}
+
+// truncated records that dropped bytes were cut from the end of the log.
+func (l *LogMessage) truncated(dropped int) {
+	if dropped == 0 {
+		return
+	}
+	if !l.Truncated {
+		l.Truncated = true
+		l.OriginalLength = len(l.Log)
+	}
+	l.OriginalLength += dropped
 }
 
-type ComponentInput interface {
-	Publish(parser DateParser, logType string) (time.Time, time.Time, error) // Publish should read the contents of component logs and publish them to the payload endpoint.
-	ComponentName() string
+// appendLine adds a continuation line, with dropped bytes cut from its end,
+// to the log.
+func (l *LogMessage) appendLine(line string, dropped int) {
+	l.Log = l.Log + line
+	if l.Truncated {
+		l.OriginalLength += len(line)
+	}
+	l.truncated(dropped)
+}
+
+// DocumentID returns an ID that is the same each time the log is read from
+// the bundle, so publishing a bundle again replaces the logs rather than
+// duplicating them.
+func (l LogMessage) DocumentID() string {
+	hash := sha256.New()
+	for _, field := range []string{
+		l.ClusterID,
+		l.NodeName,
+		l.Component,
+		l.BundleFile,
+		strconv.FormatInt(l.BundleOffset, 10),
+	} {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:20])
+}
+
+type Sink interface {
+	Write(ctx context.Context, logs []LogMessage) error // Write should send the logs to the backend, it may buffer them until Flush is called.  The slice must not be retained after Write returns.
+	Flush(ctx context.Context) error                    // Flush should wait until all logs written so far have been stored, or have failed.
+	Stats() SinkStats                                   // Stats should return the totals for all logs written to the sink.
+	Close(ctx context.Context) error                    // Close should flush the sink and release any resources it holds.
+}
+
+type AcknowledgingSink interface {
+	Sink
+	OnStored(fn func(log LogMessage)) // OnStored should register fn, alongside any registered before, to be called with each log once the backend has stored it.
+}
+
+type FailureReportingSink interface {
+	Sink
+	OnFailed(fn func(log LogMessage)) // OnFailed should register fn, alongside any registered before, to be called with each log the backend rejected or couldn't be sent.
+}
+
+type ProgressReporter interface {
+	Read(bytes int64, logs uint64) // Read should record that bytes more of a file have been read and logs more logs parsed from them.
+}
+
+type SinkStats struct {
+	NumAdded   uint64
+	NumFlushed uint64
+	NumFailed  uint64
+	NumSkipped uint64
 }
 
 type DateParser interface {
-	ParseTimestamp(log string) (time.Time, string, bool) // Parse timestamp should have the implementation for parsing the timestamp from a log line
+	ParseTimestamp(log string) (time.Time, string, bool, error) // Parse timestamp should have the implementation for parsing the timestamp from a log line.  It should return an error if the line has a timestamp that can't be parsed
+}
+
+type PartialLineParser interface {
+	DateParser
+	Partial(line string) bool                   // Partial should return whether the line is only part of a line, to be joined to the lines after it.
+	Stream(line string) string                  // Stream should return the stream the line was written to.  Partial lines are only joined to lines of the same stream.
+	Join(partials []string, line string) string // Join should return the line made by joining the partial lines onto the start of the line.
+}
+
+type Annotator interface {
+	Annotate(line string, log *LogMessage) // Annotate should add the fields parsed from the line that starts the log to it.
 }
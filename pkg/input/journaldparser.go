@@ -0,0 +1,189 @@
+package input
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+)
+
+const (
+	journaldRealtime = "__REALTIME_TIMESTAMP"
+	journaldCursor   = "__CURSOR"
+	journaldMessage  = "MESSAGE"
+	journaldPriority = "PRIORITY"
+	journaldUnit     = "_SYSTEMD_UNIT"
+	journaldProcess  = "SYSLOG_IDENTIFIER"
+	journaldPID      = "_PID"
+	journaldBootID   = "_BOOT_ID"
+	journaldHostname = "_HOSTNAME"
+)
+
+// JournaldParser parses the output of journalctl.  Each line is checked for
+// the format it is in, so a file can hold the default text output or the JSON
+// output of journalctl -o json.  Files in the export format of journalctl -o
+// export are read by an exportReader, which turns each entry into the JSON
+// output.  The text output is parsed with a DateZoneParser, and the hostname,
+// process and PID before the message are split from the log.  Every line
+// with a timestamp and a syslog prefix starts a new log, whichever process
+// wrote it.  Each entry of the JSON and export formats is a log, with the
+// fields journald recorded for it.
+type JournaldParser struct {
+	text *DateZoneParser
+
+	// The last JSON line decoded is kept as each line is looked at more than
+	// once.
+	last      string
+	lastEntry map[string]string
+	lastErr   error
+}
+
+func NewJournaldParser(captured time.Time) *JournaldParser {
+	return &JournaldParser{
+		text: NewDateZoneParser(captured, JournaldRegex, JournaldLayout),
+	}
+}
+
+func isJournaldJSON(line string) bool {
+	return strings.HasPrefix(line, "{")
+}
+
+func (p *JournaldParser) decode(line string) (map[string]string, error) {
+	if line == p.last {
+		return p.lastEntry, p.lastErr
+	}
+	p.last = line
+
+	var raw map[string]json.RawMessage
+	p.lastErr = json.Unmarshal([]byte(line), &raw)
+	p.lastEntry = make(map[string]string, len(raw))
+	for field, value := range raw {
+		p.lastEntry[field] = journaldValue(value)
+	}
+	return p.lastEntry, p.lastErr
+}
+
+// journaldValue returns the value of a field in the JSON output.  Values
+// that aren't valid UTF-8 are written as an array of bytes, and fields that
+// appear more than once as an array of their values, of which the first is
+// used.
+func journaldValue(value json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	var data []byte
+	var numbers []int
+	if err := json.Unmarshal(value, &numbers); err == nil {
+		for _, n := range numbers {
+			data = append(data, byte(n))
+		}
+		return string(data)
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal(value, &values); err == nil && len(values) > 0 {
+		return journaldValue(values[0])
+	}
+	return ""
+}
+
+// syslogPrefix is the start of a line of the text output, after the
+// timestamp.
+type syslogPrefix struct {
+	hostname string
+	process  string
+	pid      int
+	message  string
+}
+
+// splitSyslogPrefix splits the hostname and process, with the PID in square
+// brackets after the process if it has one, from the message that follows a
+// colon.
+func splitSyslogPrefix(log string) (syslogPrefix, bool) {
+	var prefix syslogPrefix
+	space := strings.IndexByte(log, ' ')
+	if space <= 0 {
+		return prefix, false
+	}
+	prefix.hostname = log[:space]
+	rest := log[space+1:]
+
+	colon := strings.IndexByte(rest, ':')
+	if colon <= 0 || strings.IndexByte(rest[:colon], ' ') >= 0 {
+		return prefix, false
+	}
+	prefix.process = rest[:colon]
+	prefix.message = strings.TrimPrefix(rest[colon+1:], " ")
+
+	if open := strings.IndexByte(prefix.process, '['); open > 0 && strings.HasSuffix(prefix.process, "]") {
+		if pid, err := strconv.Atoi(prefix.process[open+1 : len(prefix.process)-1]); err == nil {
+			prefix.pid = pid
+			prefix.process = prefix.process[:open]
+		}
+	}
+	return prefix, true
+}
+
+// textPrefix returns the syslog prefix of a line of the text output.  False
+// is returned if the line doesn't start with a timestamp, as it continues the
+// message of the line before.
+func (p *JournaldParser) textPrefix(line string) (syslogPrefix, bool) {
+	start, end := p.text.datetime.find(line)
+	if start < 0 {
+		return syslogPrefix{}, false
+	}
+	return splitSyslogPrefix(strings.TrimSpace(line[:start] + line[end:]))
+}
+
+func (p *JournaldParser) ParseTimestamp(log string) (time.Time, string, bool, error) {
+	if !isJournaldJSON(log) {
+		datetime, message, valid, err := p.text.ParseTimestamp(log)
+		if err != nil {
+			return datetime, message, valid, err
+		}
+		if prefix, ok := p.textPrefix(log); ok {
+			message = prefix.message
+			valid = true
+		}
+		return datetime, message, valid, nil
+	}
+
+	entry, err := p.decode(log)
+	if err != nil {
+		return time.Time{}, log, false, errors.ErrInvalidTimestampWithValue("", err.Error())
+	}
+	realtime := entry[journaldRealtime]
+	micros, err := strconv.ParseInt(realtime, 10, 64)
+	if err != nil {
+		return time.Time{}, log, false, errors.ErrInvalidTimestampWithValue(realtime, err.Error())
+	}
+	return time.UnixMicro(micros).UTC(), entry[journaldMessage], true, nil
+}
+
+func (p *JournaldParser) Annotate(line string, log *LogMessage) {
+	if !isJournaldJSON(line) {
+		if prefix, ok := p.textPrefix(line); ok {
+			log.Hostname = prefix.hostname
+			log.Process = prefix.process
+			log.PID = prefix.pid
+		}
+		return
+	}
+	entry, err := p.decode(line)
+	if err != nil {
+		return
+	}
+
+	log.Unit = entry[journaldUnit]
+	log.Process = entry[journaldProcess]
+	log.BootID = entry[journaldBootID]
+	log.Hostname = entry[journaldHostname]
+	if pid, err := strconv.Atoi(entry[journaldPID]); err == nil {
+		log.PID = pid
+	}
+	if priority, err := strconv.Atoi(entry[journaldPriority]); err == nil {
+		log.Priority = &priority
+	}
+}
@@ -0,0 +1,123 @@
+package input
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// memorySink keeps the logs written to it.
+type memorySink struct {
+	logs []LogMessage
+}
+
+func (s *memorySink) Write(_ context.Context, logs []LogMessage) error {
+	s.logs = append(s.logs, logs...)
+	return nil
+}
+
+func (s *memorySink) Flush(context.Context) error { return nil }
+func (s *memorySink) Stats() SinkStats            { return SinkStats{NumAdded: uint64(len(s.logs))} }
+func (s *memorySink) Close(context.Context) error { return nil }
+
+// publishTestFile publishes a file holding content with the parser and
+// returns the logs.
+func publishTestFile(t testing.TB, parser DateParser, content string) []LogMessage {
+	t.Helper()
+	sink := &memorySink{}
+	input := NewFileInput(context.Background(), sink, FileConfig{
+		Bundle: fstest.MapFS{
+			"log": &fstest.MapFile{Data: []byte(content)},
+		},
+		Paths: []string{"log"},
+	})
+	if _, _, err := input.Publish(parser, LogTypeControlplane); err != nil {
+		t.Fatalf("Publish: %s", err)
+	}
+	return sink.logs
+}
+
+func TestJournaldTextLines(t *testing.T) {
+	captured := time.Date(2022, time.January, 2, 16, 0, 0, 0, time.UTC)
+	content := "-- Logs begin at Sun 2022-01-02 10:00:00 UTC, end at Sun 2022-01-02 16:00:00 UTC. --\n" +
+		"Jan 02 15:04:05 node1 systemd[1]: Starting Lightweight Kubernetes...\n" +
+		"Jan 02 15:04:06 node1 k3s[856]: time=\"2022-01-02T15:04:06Z\" level=info msg=\"Starting k3s v1.22.5+k3s1\"\n" +
+		"Jan 02 15:04:07 node1 k3s[856]: I0102 15:04:07.123456     856 server.go:77] Version: v1.22.5+k3s1\n" +
+		"Jan 02 15:04:07 node1 k3s[856]: goroutine 1 [running]:\n" +
+		"Jan  2 15:04:08 node1 kernel: cni0: port 1(veth1) entered forwarding state\n" +
+		"                                 continued on the next line\n" +
+		"Jan 02 15:04:09 node1 systemd[1]: Started Lightweight Kubernetes.\n"
+
+	logs := publishTestFile(t, NewKlogParser(NewJournaldParser(captured)), content)
+
+	want := []struct {
+		process string
+		pid     int
+		log     string
+	}{
+		{"systemd", 1, "Starting Lightweight Kubernetes..."},
+		{"k3s", 856, `time="2022-01-02T15:04:06Z" level=info msg="Starting k3s v1.22.5+k3s1"`},
+		{"k3s", 856, "Version: v1.22.5+k3s1"},
+		{"k3s", 856, "goroutine 1 [running]:"},
+		{"kernel", 0, "cni0: port 1(veth1) entered forwarding state                                 continued on the next line"},
+		{"systemd", 1, "Started Lightweight Kubernetes."},
+	}
+	if len(logs) != len(want) {
+		for _, log := range logs {
+			t.Logf("%q", log.Log)
+		}
+		t.Fatalf("got %d logs, want %d", len(logs), len(want))
+	}
+	for i, log := range logs {
+		if log.Process != want[i].process || log.PID != want[i].pid || log.Log != want[i].log {
+			t.Errorf("log %d = %s[%d] %q, want %s[%d] %q", i, log.Process, log.PID, log.Log, want[i].process, want[i].pid, want[i].log)
+		}
+		if log.Hostname != "node1" {
+			t.Errorf("log %d hostname = %q, want node1", i, log.Hostname)
+		}
+	}
+	if want := time.Date(2022, time.January, 2, 15, 4, 8, 0, time.UTC); !logs[4].Timestamp.Equal(want) {
+		t.Errorf("single digit day timestamp = %s, want %s", logs[4].Timestamp, want)
+	}
+}
+
+func TestSplitSyslogPrefix(t *testing.T) {
+	tests := []struct {
+		log    string
+		prefix syslogPrefix
+		ok     bool
+	}{
+		{
+			log:    "node1 k3s[856]: started",
+			prefix: syslogPrefix{hostname: "node1", process: "k3s", pid: 856, message: "started"},
+			ok:     true,
+		},
+		{
+			log:    "node1 kernel: eth0: link up",
+			prefix: syslogPrefix{hostname: "node1", process: "kernel", message: "eth0: link up"},
+			ok:     true,
+		},
+		{
+			log:    "node1 containerd[12]:",
+			prefix: syslogPrefix{hostname: "node1", process: "containerd", pid: 12},
+			ok:     true,
+		},
+		{
+			log: "node1 no colon here",
+		},
+		{
+			log: "continued: text",
+		},
+	}
+	for _, tt := range tests {
+		prefix, ok := splitSyslogPrefix(tt.log)
+		if ok != tt.ok {
+			t.Errorf("splitSyslogPrefix(%q) ok = %t, want %t", tt.log, ok, tt.ok)
+			continue
+		}
+		if ok && prefix != tt.prefix {
+			t.Errorf("splitSyslogPrefix(%q) = %+v, want %+v", tt.log, prefix, tt.prefix)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package input
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// klogLevels are the names of the klog severities.
+var klogLevels = map[byte]string{
+	'I': "info",
+	'W': "warning",
+	'E': "error",
+	'F': "fatal",
+}
+
+// KlogParser parses logs that may be written by klog with another parser, and
+// splits the klog header from the message of each log that has one.  The
+// severity, thread ID and the file and line that wrote the log are recorded
+// in the level, thread_id, source_file and source_line fields.  The thread ID
+// isn't stored in pid because journald logs already keep the PID of the
+// process there, and a klog log read from journald has both.  The key value
+// pairs of structured logs are split from the message into the fields object.
+type KlogParser struct {
+	parser DateParser
+
+	// The header of the last line parsed is kept for Annotate.
+	last       string
+	lastHeader klogHeader
+	lastOK     bool
+}
+
+// klogHeader is the header klog writes before each message:
+//
+//	Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg
+type klogHeader struct {
+	level    string
+	threadID int
+	file     string
+	line     int
+	message  string
+	// fields are the key value pairs of a structured log.
+	fields map[string]string
+}
+
+func NewKlogParser(parser DateParser) *KlogParser {
+	return &KlogParser{
+		parser: parser,
+	}
+}
+
+// splitKlogHeader splits the klog header from the start of the log.
+func splitKlogHeader(log string) (klogHeader, bool) {
+	var header klogHeader
+	if len(log) == 0 {
+		return header, false
+	}
+	level, ok := klogLevels[log[0]]
+	if !ok {
+		return header, false
+	}
+	n := matchShape(log[1:], "9999 99:99:99.999999 ")
+	if n < 0 {
+		return header, false
+	}
+	rest := strings.TrimLeft(log[1+n:], " ")
+
+	space := strings.IndexByte(rest, ' ')
+	if space < 0 {
+		return header, false
+	}
+	threadID, err := strconv.Atoi(rest[:space])
+	if err != nil {
+		return header, false
+	}
+	rest = rest[space+1:]
+
+	end := strings.Index(rest, "] ")
+	if end < 0 {
+		if !strings.HasSuffix(rest, "]") {
+			return header, false
+		}
+		end = len(rest) - 1
+	}
+	colon := strings.LastIndexByte(rest[:end], ':')
+	if colon <= 0 {
+		return header, false
+	}
+	line, err := strconv.Atoi(rest[colon+1 : end])
+	if err != nil {
+		return header, false
+	}
+
+	header.level = level
+	header.threadID = threadID
+	header.file = rest[:colon]
+	header.line = line
+	header.message = strings.TrimPrefix(rest[end+1:], " ")
+	if message, fields, ok := splitStructured(header.message); ok {
+		header.message = message
+		header.fields = fields
+	}
+	return header, true
+}
+
+// splitStructured splits a structured log, a quoted message followed by key
+// value pairs, into the message and the pairs.  False is returned if the log
+// isn't structured.
+func splitStructured(log string) (string, map[string]string, bool) {
+	if !strings.HasPrefix(log, `"`) {
+		return "", nil, false
+	}
+	quoted, err := strconv.QuotedPrefix(log)
+	if err != nil {
+		return "", nil, false
+	}
+	message, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", nil, false
+	}
+
+	var fields map[string]string
+	rest := log[len(quoted):]
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		if rest == "" {
+			break
+		}
+		equals := strings.IndexByte(rest, '=')
+		if equals <= 0 || strings.ContainsAny(rest[:equals], ` "`) {
+			return "", nil, false
+		}
+		value, n, ok := klogValue(rest[equals+1:])
+		if !ok {
+			return "", nil, false
+		}
+		if fields == nil {
+			fields = map[string]string{}
+		}
+		fields[rest[:equals]] = value
+		rest = rest[equals+1+n:]
+	}
+	return message, fields, true
+}
+
+// klogValue returns the value at the start of s and its length in s.
+// Strings are quoted, and slices and structs are in brackets and may hold
+// spaces.  Anything else ends at the next space.
+func klogValue(s string) (string, int, bool) {
+	if strings.HasPrefix(s, `"`) {
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", 0, false
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", 0, false
+		}
+		return value, len(quoted), true
+	}
+
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		depth := 0
+		for i := 0; i < len(s); i++ {
+			switch s[i] {
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					return s[:i+1], i + 1, true
+				}
+			}
+		}
+		return s, len(s), true
+	}
+
+	end := strings.IndexByte(s, ' ')
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], end, true
+}
+
+// setObjectReferences copies the Kubernetes objects the log is about from the
+// fields of a structured log.  Pods and objects are named namespace/name, so
+// each can be found across the logs of all the components.
+func setObjectReferences(log *LogMessage, fields map[string]string) {
+	log.Namespace = fields["namespace"]
+	log.Node = fields["node"]
+	log.Pod = qualifyName(fields["pod"], log.Namespace)
+	log.Object = qualifyName(fields["object"], log.Namespace)
+	switch {
+	case log.Pod == "" && fields["kind"] == "Pod":
+		log.Pod = log.Object
+	case log.Node == "" && fields["kind"] == "Node":
+		log.Node = log.Object
+	}
+
+	if log.Namespace == "" {
+		for _, name := range []string{log.Pod, log.Object} {
+			if slash := strings.IndexByte(name, '/'); slash > 0 {
+				log.Namespace = name[:slash]
+				break
+			}
+		}
+	}
+}
+
+// qualifyName adds the namespace to the name of an object if it doesn't
+// already have one.
+func qualifyName(name string, namespace string) string {
+	if name == "" || namespace == "" || strings.Contains(name, "/") {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+func (p *KlogParser) ParseTimestamp(log string) (time.Time, string, bool, error) {
+	datetime, message, valid, err := p.parser.ParseTimestamp(log)
+	if err != nil || !valid {
+		return datetime, message, valid, err
+	}
+
+	p.last = log
+	p.lastHeader, p.lastOK = splitKlogHeader(message)
+	if p.lastOK {
+		message = p.lastHeader.message
+	}
+	return datetime, message, valid, nil
+}
+
+func (p *KlogParser) Partial(line string) bool {
+	if partialParser, ok := p.parser.(PartialLineParser); ok {
+		return partialParser.Partial(line)
+	}
+	return false
+}
+
+func (p *KlogParser) Stream(line string) string {
+	if partialParser, ok := p.parser.(PartialLineParser); ok {
+		return partialParser.Stream(line)
+	}
+	return ""
+}
+
+func (p *KlogParser) Join(partials []string, line string) string {
+	if partialParser, ok := p.parser.(PartialLineParser); ok {
+		return partialParser.Join(partials, line)
+	}
+	return line
+}
+
+func (p *KlogParser) Annotate(line string, log *LogMessage) {
+	if annotator, ok := p.parser.(Annotator); ok {
+		annotator.Annotate(line, log)
+	}
+	if line != p.last || !p.lastOK {
+		return
+	}
+	log.Level = p.lastHeader.level
+	log.ThreadID = p.lastHeader.threadID
+	log.SourceFile = p.lastHeader.file
+	log.SourceLine = p.lastHeader.line
+	if p.lastHeader.fields != nil {
+		log.Fields = p.lastHeader.fields
+		setObjectReferences(log, p.lastHeader.fields)
+	}
+}
@@ -0,0 +1,373 @@
+package input
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitKlogHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		log    string
+		header klogHeader
+		ok     bool
+	}{
+		{
+			name: "info",
+			log:  "I0102 15:04:05.123456    1234 controller.go:123] synced",
+			header: klogHeader{
+				level:    "info",
+				threadID: 1234,
+				file:     "controller.go",
+				line:     123,
+				message:  "synced",
+			},
+			ok: true,
+		},
+		{
+			name: "error with path",
+			log:  "E0102 15:04:05.123456 7 pkg/kubelet/kubelet.go:2183] failed",
+			header: klogHeader{
+				level:    "error",
+				threadID: 7,
+				file:     "pkg/kubelet/kubelet.go",
+				line:     2183,
+				message:  "failed",
+			},
+			ok: true,
+		},
+		{
+			name: "empty message",
+			log:  "W0102 15:04:05.123456    1234 controller.go:123]",
+			header: klogHeader{
+				level:    "warning",
+				threadID: 1234,
+				file:     "controller.go",
+				line:     123,
+			},
+			ok: true,
+		},
+		{
+			name: "structured",
+			log:  `I0102 15:04:05.123456    1234 scheduler.go:90] "Successfully bound pod" pod="kube-system/coredns-1" node="node1" evaluatedNodes=3`,
+			header: klogHeader{
+				level:    "info",
+				threadID: 1234,
+				file:     "scheduler.go",
+				line:     90,
+				message:  "Successfully bound pod",
+				fields: map[string]string{
+					"pod":            "kube-system/coredns-1",
+					"node":           "node1",
+					"evaluatedNodes": "3",
+				},
+			},
+			ok: true,
+		},
+		{
+			name: "unknown severity",
+			log:  "X0102 15:04:05.123456    1234 controller.go:123] synced",
+		},
+		{
+			name: "no thread ID",
+			log:  "I0102 15:04:05.123456 controller.go:123] synced",
+		},
+		{
+			name: "no line",
+			log:  "I0102 15:04:05.123456    1234 controller.go] synced",
+		},
+		{
+			name: "not klog",
+			log:  "time=\"2022-01-02T15:04:05Z\" level=info msg=\"Starting k3s\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, ok := splitKlogHeader(tt.log)
+			if ok != tt.ok {
+				t.Fatalf("splitKlogHeader(%q) ok = %t, want %t", tt.log, ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(header, tt.header) {
+				t.Errorf("splitKlogHeader(%q) = %+v, want %+v", tt.log, header, tt.header)
+			}
+		})
+	}
+}
+
+func TestSplitStructured(t *testing.T) {
+	tests := []struct {
+		name    string
+		log     string
+		message string
+		fields  map[string]string
+		ok      bool
+	}{
+		{
+			name:    "message only",
+			log:     `"Starting controller"`,
+			message: "Starting controller",
+			ok:      true,
+		},
+		{
+			name:    "quoted and bracketed values",
+			log:     `"Update failed" err="context deadline exceeded" keys=[a b] obj={Name:x Kind:Pod} count=2`,
+			message: "Update failed",
+			fields: map[string]string{
+				"err":   "context deadline exceeded",
+				"keys":  "[a b]",
+				"obj":   "{Name:x Kind:Pod}",
+				"count": "2",
+			},
+			ok: true,
+		},
+		{
+			name:    "escaped quotes",
+			log:     `"Said \"hi\"" who="a \"b\""`,
+			message: `Said "hi"`,
+			fields: map[string]string{
+				"who": `a "b"`,
+			},
+			ok: true,
+		},
+		{
+			name: "not quoted",
+			log:  "Starting controller name=x",
+		},
+		{
+			name: "text after message",
+			log:  `"Starting" controller`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, fields, ok := splitStructured(tt.log)
+			if ok != tt.ok {
+				t.Fatalf("splitStructured(%q) ok = %t, want %t", tt.log, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if message != tt.message {
+				t.Errorf("message = %q, want %q", message, tt.message)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestSetObjectReferences(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]string
+		want   LogMessage
+	}{
+		{
+			name:   "qualified pod",
+			fields: map[string]string{"pod": "kube-system/coredns-1", "node": "node1"},
+			want: LogMessage{
+				Pod:       "kube-system/coredns-1",
+				Node:      "node1",
+				Namespace: "kube-system",
+			},
+		},
+		{
+			name:   "pod with namespace field",
+			fields: map[string]string{"pod": "coredns-1", "namespace": "kube-system"},
+			want: LogMessage{
+				Pod:       "kube-system/coredns-1",
+				Namespace: "kube-system",
+			},
+		},
+		{
+			name:   "pod object",
+			fields: map[string]string{"object": "default/web-0", "kind": "Pod"},
+			want: LogMessage{
+				Pod:       "default/web-0",
+				Object:    "default/web-0",
+				Namespace: "default",
+			},
+		},
+		{
+			name:   "node object",
+			fields: map[string]string{"object": "node1", "kind": "Node"},
+			want: LogMessage{
+				Node:   "node1",
+				Object: "node1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log LogMessage
+			setObjectReferences(&log, tt.fields)
+			if !reflect.DeepEqual(log, tt.want) {
+				t.Errorf("setObjectReferences(%v) = %+v, want %+v", tt.fields, log, tt.want)
+			}
+		})
+	}
+}
+
+func TestKlogParserAnnotate(t *testing.T) {
+	captured := time.Date(2022, time.January, 2, 16, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		parser  DateParser
+		line    string
+		message string
+		want    LogMessage
+	}{
+		{
+			name:    "klog",
+			parser:  NewKlogParser(NewDateZoneParser(captured, KlogRegex, KlogLayout)),
+			line:    "E0102 15:04:05.123456    1234 controller.go:123] sync failed",
+			message: "sync failed",
+			want: LogMessage{
+				Level:      "error",
+				ThreadID:   1234,
+				SourceFile: "controller.go",
+				SourceLine: 123,
+			},
+		},
+		{
+			name:    "journald keeps the PID of the process",
+			parser:  NewKlogParser(NewJournaldParser(captured)),
+			line:    "Jan 02 15:04:05 node1 k3s[856]: I0102 15:04:05.123456     901 node.go:47] Registered",
+			message: "Registered",
+			want: LogMessage{
+				Hostname:   "node1",
+				Process:    "k3s",
+				PID:        856,
+				Level:      "info",
+				ThreadID:   901,
+				SourceFile: "node.go",
+				SourceLine: 47,
+			},
+		},
+		{
+			name:    "CRI structured",
+			parser:  NewKlogParser(NewCRIParser(KlogRegex)),
+			line:    `2022-01-02T15:04:05.123456789Z stderr F I0102 15:04:05.123456       1 event.go:294] "Event occurred" object="kube-system/coredns-1" kind="Pod" reason="Scheduled"`,
+			message: "Event occurred",
+			want: LogMessage{
+				Stream:     "stderr",
+				Level:      "info",
+				ThreadID:   1,
+				SourceFile: "event.go",
+				SourceLine: 294,
+				Fields: map[string]string{
+					"object": "kube-system/coredns-1",
+					"kind":   "Pod",
+					"reason": "Scheduled",
+				},
+				Pod:       "kube-system/coredns-1",
+				Namespace: "kube-system",
+				Object:    "kube-system/coredns-1",
+			},
+		},
+		{
+			name:    "not klog",
+			parser:  NewKlogParser(NewJournaldParser(captured)),
+			line:    "Jan 02 15:04:05 node1 systemd[1]: Started k3s.",
+			message: "Started k3s.",
+			want: LogMessage{
+				Hostname: "node1",
+				Process:  "systemd",
+				PID:      1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, message, _, err := tt.parser.ParseTimestamp(tt.line)
+			if err != nil {
+				t.Fatalf("ParseTimestamp(%q) error: %s", tt.line, err)
+			}
+			if message != tt.message {
+				t.Errorf("message = %q, want %q", message, tt.message)
+			}
+			var log LogMessage
+			tt.parser.(Annotator).Annotate(tt.line, &log)
+			if !reflect.DeepEqual(log, tt.want) {
+				t.Errorf("Annotate(%q) = %+v, want %+v", tt.line, log, tt.want)
+			}
+		})
+	}
+}
+
+func TestKlogValue(t *testing.T) {
+	tests := []struct {
+		s      string
+		value  string
+		length int
+	}{
+		{`"quoted value" next=1`, "quoted value", 14},
+		{`[a b [c]] next=1`, "[a b [c]]", 9},
+		{`{Name:x Spec:{Replicas:1}} next=1`, "{Name:x Spec:{Replicas:1}}", 26},
+		{`[unclosed`, "[unclosed", 9},
+		{`plain next=1`, "plain", 5},
+		{`last`, "last", 4},
+	}
+	for _, tt := range tests {
+		value, length, ok := klogValue(tt.s)
+		if !ok || value != tt.value || length != tt.length {
+			t.Errorf("klogValue(%q) = %q, %d, %t, want %q, %d", tt.s, value, length, ok, tt.value, tt.length)
+		}
+	}
+	if _, _, ok := klogValue(`"unterminated`); ok {
+		t.Errorf("klogValue of an unterminated string ok = true, want false")
+	}
+}
+
+func TestStructuredKlogLogs(t *testing.T) {
+	content := `2022-01-02T15:04:05.100000000Z stderr F I0102 15:04:05.100000       1 scheduler.go:90] "Successfully bound pod to node" pod="kube-system/coredns-1" node="node1" evaluatedNodes=3` + "\n" +
+		`2022-01-02T15:04:05.200000000Z stderr F E0102 15:04:05.200000       1 kubelet.go:2183] "Error syncing pod, skipping" err="failed to \"StartContainer\"" pod="web-0" namespace="default"` + "\n" +
+		`2022-01-02T15:04:05.300000000Z stderr F I0102 15:04:05.300000       1 controller.go:42] Not structured key="value"` + "\n"
+
+	logs := publishTestFile(t, NewKlogParser(NewCRIParser(KlogRegex)), content)
+
+	want := []LogMessage{
+		{
+			Log: "Successfully bound pod to node",
+			Fields: map[string]string{
+				"pod":            "kube-system/coredns-1",
+				"node":           "node1",
+				"evaluatedNodes": "3",
+			},
+			Pod:       "kube-system/coredns-1",
+			Node:      "node1",
+			Namespace: "kube-system",
+		},
+		{
+			Log: "Error syncing pod, skipping",
+			Fields: map[string]string{
+				"err":       `failed to "StartContainer"`,
+				"pod":       "web-0",
+				"namespace": "default",
+			},
+			Pod:       "default/web-0",
+			Namespace: "default",
+		},
+		{
+			Log: `Not structured key="value"`,
+		},
+	}
+	if len(logs) != len(want) {
+		t.Fatalf("got %d logs, want %d", len(logs), len(want))
+	}
+	for i, log := range logs {
+		got := LogMessage{
+			Log:       log.Log,
+			Fields:    log.Fields,
+			Pod:       log.Pod,
+			Node:      log.Node,
+			Namespace: log.Namespace,
+			Object:    log.Object,
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("log %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package input
+
+import (
+	"bufio"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxLineSize is the longest line read when FileConfig doesn't
+	// set one.
+	DefaultMaxLineSize = 1024 * 1024
+)
+
+// logReader reads a log file a line at a time, tracking where in the file
+// each line starts.
+type logReader interface {
+	Scan() bool        // Scan should read the next line, returning false at the end of the file or if the read failed.
+	Text() string      // Text should return the line read by Scan.
+	Dropped() int      // Dropped should return the number of bytes cut from the end of the line.
+	LineOffset() int64 // LineOffset should return where the line starts in the file.
+	Err() error        // Err should return the error that stopped the read, if it wasn't the end of the file.
+}
+
+// newLogReader returns a reader for the lines of the file, or for the
+// entries of the file if it is in the journald export format.
+func newLogReader(r io.Reader, maxSize int) logReader {
+	reader := bufio.NewReader(r)
+	if start, _ := reader.Peek(len(journaldCursor) + 1); string(start) == journaldCursor+"=" {
+		return newExportReader(reader, maxSize)
+	}
+	return newLineReader(reader, maxSize)
+}
+
+// lineReader reads the lines of a file like bufio.Scanner, but lines longer
+// than the maximum size are truncated instead of stopping the read.  It also
+// tracks the offset of each line in the file.
+type lineReader struct {
+	reader  *bufio.Reader
+	maxSize int
+
+	line []byte
+	// length is the length of the line before it was truncated.
+	length int
+	// offset is where the next line starts, lineOffset is where the current
+	// line starts.
+	offset     int64
+	lineOffset int64
+	err        error
+}
+
+func newLineReader(r io.Reader, maxSize int) *lineReader {
+	return &lineReader{
+		reader:  bufio.NewReader(r),
+		maxSize: maxSize,
+	}
+}
+
+// Scan reads the next line, returning false at the end of the file or if
+// the read failed.
+func (l *lineReader) Scan() bool {
+	if l.err != nil {
+		return false
+	}
+	l.line = l.line[:0]
+	l.length = 0
+	l.lineOffset = l.offset
+
+	var newline bool
+	for {
+		chunk, err := l.reader.ReadSlice('\n')
+		l.offset += int64(len(chunk))
+		l.length += len(chunk)
+		if room := l.maxSize - len(l.line); room > 0 {
+			if len(chunk) < room {
+				room = len(chunk)
+			}
+			l.line = append(l.line, chunk[:room]...)
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			if l.length == 0 {
+				return false
+			}
+			break
+		}
+		if err != nil {
+			l.err = err
+			return false
+		}
+		newline = true
+		break
+	}
+
+	// Drop the line ending, the same as bufio.ScanLines
+	if newline {
+		l.length--
+		if len(l.line) > l.length {
+			l.line = l.line[:l.length]
+		}
+	}
+	if len(l.line) == l.length && l.length > 0 && l.line[l.length-1] == '\r' {
+		l.length--
+		l.line = l.line[:l.length]
+	}
+
+	if l.Truncated() {
+		l.line = trimPartialRune(l.line)
+	}
+	return true
+}
+
+// trimPartialRune cuts half a character from the end of a truncated line.
+func trimPartialRune(line []byte) []byte {
+	start := len(line) - 1
+	for start > 0 && !utf8.RuneStart(line[start]) {
+		start--
+	}
+	if start >= 0 && !utf8.FullRune(line[start:]) {
+		return line[:start]
+	}
+	return line
+}
+
+func (l *lineReader) Text() string {
+	return string(l.line)
+}
+
+// Truncated returns whether the line was longer than the maximum size.
+func (l *lineReader) Truncated() bool {
+	return l.length > len(l.line)
+}
+
+// Dropped returns the number of bytes cut from the end of the line.
+func (l *lineReader) Dropped() int {
+	return l.length - len(l.line)
+}
+
+// LineOffset returns where the line starts in the file.
+func (l *lineReader) LineOffset() int64 {
+	return l.lineOffset
+}
+
+func (l *lineReader) Err() error {
+	return l.err
+}
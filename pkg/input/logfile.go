@@ -0,0 +1,135 @@
+package input
+
+import (
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+const (
+	// firstTimestampLines is how many lines are read looking for the first
+	// timestamp, or hostname, in a file.
+	firstTimestampLines = 1000
+)
+
+// logFile is a log file in the bundle, decompressed if it is gzipped.
+type logFile struct {
+	io.Reader
+	file fs.File
+	raw  *countingReader
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func openLogFile(bundle fs.FS, path string) (*logFile, error) {
+	file, err := bundle.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := &countingReader{Reader: file}
+	logFile := &logFile{
+		Reader: raw,
+		file:   file,
+		raw:    raw,
+	}
+	if strings.HasSuffix(path, ".gz") {
+		decompressed, err := gzip.NewReader(raw)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		logFile.Reader = decompressed
+	}
+	return logFile, nil
+}
+
+// BytesRead returns how much of the file in the bundle has been read, before
+// it was decompressed.
+func (f *logFile) BytesRead() int64 {
+	return f.raw.n
+}
+
+func (f *logFile) Close() error {
+	return f.file.Close()
+}
+
+// FirstTimestamp returns the timestamp of the first log in the file.  False
+// is returned if there isn't one near the start of the file.
+func FirstTimestamp(bundle fs.FS, path string, parser DateParser) (time.Time, bool, error) {
+	var first time.Time
+	found, err := scanStart(bundle, path, parser, func(line string) bool {
+		datetime, _, valid, err := parser.ParseTimestamp(line)
+		if err == nil && valid {
+			first = datetime
+			return true
+		}
+		return false
+	})
+	return first, found, err
+}
+
+// FirstHostname returns the hostname of the first log in the file that has
+// one.  An empty string is returned if there isn't one near the start of the
+// file.
+func FirstHostname(bundle fs.FS, path string, parser DateParser) (string, error) {
+	annotator, ok := parser.(Annotator)
+	if !ok {
+		return "", nil
+	}
+
+	var hostname string
+	_, err := scanStart(bundle, path, parser, func(line string) bool {
+		_, _, valid, err := parser.ParseTimestamp(line)
+		if err != nil || !valid {
+			return false
+		}
+		var log LogMessage
+		annotator.Annotate(line, &log)
+		hostname = log.Hostname
+		return hostname != ""
+	})
+	return hostname, err
+}
+
+// scanStart calls found with the lines near the start of the file, joining
+// partial lines back together, until it returns true.
+func scanStart(bundle fs.FS, path string, parser DateParser, found func(line string) bool) (bool, error) {
+	file, err := openLogFile(bundle, path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	partialParser, _ := parser.(PartialLineParser)
+	var partials []string
+
+	reader := newLogReader(file, DefaultMaxLineSize)
+	for lines := 0; lines < firstTimestampLines && reader.Scan(); lines++ {
+		line := reader.Text()
+		if partialParser != nil {
+			if partialParser.Partial(line) {
+				partials = append(partials, line)
+				continue
+			}
+			line = partialParser.Join(partials, line)
+			partials = partials[:0]
+		}
+		if found(line) {
+			return true, nil
+		}
+	}
+	return false, reader.Err()
+}
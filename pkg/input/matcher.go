@@ -0,0 +1,136 @@
+package input
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// timestampMatcher finds a timestamp in a log line.  The timestamp patterns
+// used by the parsers are checked by hand, which is much quicker than
+// running the regex on every line.  Any other pattern uses the regex.
+type timestampMatcher struct {
+	re *regexp.Regexp
+	// shapes are the forms the timestamp can take, see matchShape.
+	shapes []string
+	// anchored is set if the timestamp must be at the start of the line.
+	anchored bool
+}
+
+// timestampShapes describes the patterns that can be matched without the
+// regex.
+var timestampShapes = map[string]struct {
+	shapes   []string
+	anchored bool
+}{
+	EtcdRegex:     {[]string{"9999-99-99 99:99:99?999999"}, true},
+	RancherRegex:  {[]string{"9999/99/99 99:99:99"}, true},
+	JournaldRegex: {[]string{"Aaa 99 99:99:99", "Aaa 9 99:99:99", "Aaa  99 99:99:99", "Aaa  9 99:99:99"}, true},
+	KlogRegex:     {[]string{"9999 99:99:99?999999"}, false},
+}
+
+func newTimestampMatcher(pattern string) *timestampMatcher {
+	matcher := &timestampMatcher{
+		re: regexp.MustCompile(pattern),
+	}
+	if shapes, ok := timestampShapes[pattern]; ok {
+		matcher.shapes = shapes.shapes
+		matcher.anchored = shapes.anchored
+	}
+	return matcher
+}
+
+// find returns the start and end of the first timestamp in the log, or -1 if
+// there isn't one.
+func (m *timestampMatcher) find(log string) (int, int) {
+	if len(m.shapes) == 0 {
+		loc := m.re.FindStringIndex(log)
+		if loc == nil {
+			return -1, -1
+		}
+		return loc[0], loc[1]
+	}
+
+	if m.anchored {
+		if end := m.matchAt(log, 0); end >= 0 {
+			return 0, end
+		}
+		return -1, -1
+	}
+	// The unanchored shapes start with a digit so only check from digits
+	for start := 0; start < len(log); start++ {
+		if !isDigit(log[start]) {
+			continue
+		}
+		if end := m.matchAt(log, start); end >= 0 {
+			return start, end
+		}
+	}
+	return -1, -1
+}
+
+// findString returns the first timestamp in the log, or an empty string if
+// there isn't one.
+func (m *timestampMatcher) findString(log string) string {
+	start, end := m.find(log)
+	if start < 0 {
+		return ""
+	}
+	return log[start:end]
+}
+
+// matchAt returns the end of the timestamp starting at start, or -1 if none
+// of the shapes match there.
+func (m *timestampMatcher) matchAt(log string, start int) int {
+	for _, shape := range m.shapes {
+		if n := matchShape(log[start:], shape); n >= 0 {
+			return start + n
+		}
+	}
+	return -1
+}
+
+// matchShape returns the length of the start of s that matches shape, or -1
+// if it doesn't.  In the shape 9 matches a digit, A an upper case letter, a a
+// lower case letter and ? any character other than a newline.  Everything
+// else must match exactly.
+func matchShape(s string, shape string) int {
+	n := 0
+	for i := 0; i < len(shape); i++ {
+		if n >= len(s) {
+			return -1
+		}
+		c := s[n]
+		switch shape[i] {
+		case '9':
+			if !isDigit(c) {
+				return -1
+			}
+		case 'A':
+			if c < 'A' || c > 'Z' {
+				return -1
+			}
+		case 'a':
+			if c < 'a' || c > 'z' {
+				return -1
+			}
+		case '?':
+			if c == '\n' {
+				return -1
+			}
+			// Like the regex, any character may be more than one byte
+			_, size := utf8.DecodeRuneInString(s[n:])
+			n += size
+			continue
+		default:
+			if c != shape[i] {
+				return -1
+			}
+		}
+		n++
+	}
+	return n
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
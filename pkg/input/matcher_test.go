@@ -0,0 +1,99 @@
+package input
+
+import (
+	"regexp"
+	"testing"
+)
+
+// matcherLogs are matched against every pattern the timestampMatcher
+// handles without the regex, and must give the same result as the regex.
+var matcherLogs = []string{
+	"",
+	"no timestamp here",
+	// klog
+	"I0102 15:04:05.123456    1234 controller.go:123] synced",
+	"E1231 23:59:59.999999       1 kubelet.go:2183] failed",
+	"I0102 15:04:05.12345    1234 controller.go:123] short fraction",
+	"10102 15:04:05.123456 digits before the timestamp",
+	"I0102 15:04:05é123456 multi-byte separator",
+	"I0102 15:04:05\n123456 newline separator",
+	"2022-01-02T15:04:05.123456789Z stderr F I0102 15:04:05.123456 1 a.go:1] in a CRI line",
+	"I0102 15:04:5.123456 one digit seconds",
+	// etcd
+	"2022-01-02 15:04:05.123456 I | etcdserver: published",
+	"2022-01-02 15:04:05,123456 comma separator",
+	"2022-01-02 15:04:05€123456 multi-byte separator",
+	"2022-01-02T15:04:05.123456 T separator",
+	" 2022-01-02 15:04:05.123456 leading space",
+	// rancher
+	"2022/01/02 15:04:05 [INFO] Starting rancher",
+	"2022/1/02 15:04:05 one digit month",
+	"time=\"2022-01-02T15:04:05Z\" level=info msg=\"Starting k3s\"",
+	"x 2022/01/02 15:04:05 not at the start",
+	// journald
+	"Jan 02 15:04:05 node1 k3s[856]: started",
+	"Jan 2 15:04:05 node1 k3s[856]: one digit day",
+	"Jan  2 15:04:05 node1 k3s[856]: padded day",
+	"Jan  02 15:04:05 node1 k3s[856]: padded two digit day",
+	"Jan   2 15:04:05 node1 k3s[856]: three spaces",
+	"Jan 123 15:04:05 three digit day",
+	"JAN 02 15:04:05 upper case month",
+	"jan 02 15:04:05 lower case month",
+	"Jän 02 15:04:05 multi-byte month",
+	"Jan 02 15:04 no seconds",
+}
+
+func TestTimestampMatcherMatchesRegex(t *testing.T) {
+	for pattern := range timestampShapes {
+		matcher := newTimestampMatcher(pattern)
+		if len(matcher.shapes) == 0 {
+			t.Fatalf("%s isn't matched by shape", pattern)
+		}
+		re := regexp.MustCompile(pattern)
+		for _, log := range matcherLogs {
+			wantStart, wantEnd := -1, -1
+			if loc := re.FindStringIndex(log); loc != nil {
+				wantStart, wantEnd = loc[0], loc[1]
+			}
+			start, end := matcher.find(log)
+			if start != wantStart || end != wantEnd {
+				t.Errorf("%s: find(%q) = %d, %d, regex gives %d, %d", pattern, log, start, end, wantStart, wantEnd)
+			}
+		}
+	}
+}
+
+func TestTimestampMatcherWithoutShapes(t *testing.T) {
+	matcher := newTimestampMatcher(EtcdJSONRegex)
+	if len(matcher.shapes) != 0 {
+		t.Fatalf("%s has shapes, want the regex", EtcdJSONRegex)
+	}
+	if got := matcher.findString(`{"level":"info","ts":"2022-01-02T15:04:05.123Z"}`); got != `{"level":"` {
+		t.Errorf("findString = %q, want %q", got, `{"level":"`)
+	}
+	if start, _ := matcher.find("I0102 15:04:05.123456 not JSON"); start != -1 {
+		t.Errorf("find = %d, want -1", start)
+	}
+}
+
+func TestMatchShape(t *testing.T) {
+	tests := []struct {
+		s     string
+		shape string
+		want  int
+	}{
+		{"0102 15:04", "9999 99:99", 10},
+		{"0102 15:04 and more", "9999 99:99", 10},
+		{"0102 15:0", "9999 99:99", -1},
+		{"Jan", "Aaa", 3},
+		{"jan", "Aaa", -1},
+		{"1.2", "9?9", 3},
+		{"1é2", "9?9", 4},
+		{"1\n2", "9?9", -1},
+	}
+	for _, tt := range tests {
+		if got := matchShape(tt.s, tt.shape); got != tt.want {
+			t.Errorf("matchShape(%q, %q) = %d, want %d", tt.s, tt.shape, got, tt.want)
+		}
+	}
+}
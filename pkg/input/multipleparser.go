@@ -1,56 +1,60 @@
 package input
 
 import (
-	"fmt"
-	"regexp"
-	"strings"
 	"time"
 
-	"github.com/dbason/opni-supportagent/pkg/util"
-)
-
-const (
-	leadingDateRegex = `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{9}Z`
+	"github.com/dbason/opni-supportagent/pkg/errors"
 )
 
 type MultipleParser struct {
-	Dateformats      []Dateformat
-	StripLeadingDate bool
+	dateformats []dateformatMatcher
 }
 
 type Dateformat struct {
-	DateRegex  string
-	Layout     string
-	DateSuffix string
+	DateRegex string
+	Layout    string
+	// Captured, if set, is when the bundle was captured.  The layout has no
+	// year or timezone and they are filled in from it, see
+	// NewDateZoneParser.
+	Captured time.Time
 }
 
-func (p *MultipleParser) ParseTimestamp(log string) (time.Time, string, bool) {
-	var datetime time.Time
-	var err error
-	if p.StripLeadingDate {
-		re := regexp.MustCompile(leadingDateRegex)
-		datestring := re.FindString(log)
-		datetime, err = time.Parse(time.RFC3339Nano, datestring)
-		if err != nil {
-			util.Log.Panic(err)
-		}
-		log = strings.TrimSpace(re.ReplaceAllString(log, ""))
+type dateformatMatcher struct {
+	Dateformat
+	matcher *timestampMatcher
+}
+
+// NewMultipleParser returns a parser for logs that can have any of the date
+// formats.
+func NewMultipleParser(dateformats ...Dateformat) *MultipleParser {
+	parser := &MultipleParser{}
+	for _, dateformat := range dateformats {
+		parser.dateformats = append(parser.dateformats, dateformatMatcher{
+			Dateformat: dateformat,
+			matcher:    newTimestampMatcher(dateformat.DateRegex),
+		})
 	}
+	return parser
+}
 
-	for _, dateFormat := range p.Dateformats {
-		re := regexp.MustCompile(dateFormat.DateRegex)
-		datestring := re.FindString(log)
+func (p *MultipleParser) ParseTimestamp(log string) (time.Time, string, bool, error) {
+	for _, dateFormat := range p.dateformats {
+		datestring := dateFormat.matcher.findString(log)
 		if len(datestring) == 0 {
 			continue
 		}
-		if !p.StripLeadingDate {
-			datetime, err = time.Parse(dateFormat.Layout, fmt.Sprintf("%s%s", datestring, dateFormat.DateSuffix))
-			if err != nil {
-				util.Log.Panic(err)
-			}
+		var datetime time.Time
+		var err error
+		if dateFormat.Captured.IsZero() {
+			datetime, err = time.Parse(dateFormat.Layout, datestring)
+		} else {
+			datetime, err = parseBeforeCapture(dateFormat.Layout, datestring, dateFormat.Captured)
+		}
+		if err != nil {
+			return time.Time{}, log, false, errors.ErrInvalidTimestampWithValue(datestring, err.Error())
 		}
-		return datetime, log, true
+		return datetime, log, true, nil
 	}
 
-	return time.Now(), log, false
+	return time.Now(), log, false, nil
 }
@@ -0,0 +1,77 @@
+package input
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+)
+
+const (
+	maxDocumentSize = 16 * 1024 * 1024
+)
+
+// ImportNDJSON writes the logs in a newline delimited JSON file, as written
+// by the file sink, to the sink.  Files ending in .gz are decompressed.  All
+// the logs must belong to the case.
+func ImportNDJSON(ctx context.Context, path string, caseNumber string, sink Sink) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return 0, err
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	}
+
+	var imported uint64
+	batch := make([]LogMessage, 0, defaultBatchSize)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxDocumentSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var log LogMessage
+		if err := json.Unmarshal(scanner.Bytes(), &log); err != nil {
+			return imported, err
+		}
+		if log.ClusterID != caseNumber {
+			return imported, errors.ErrCaseMismatchWithID(log.ClusterID)
+		}
+		batch = append(batch, log)
+		if len(batch) == defaultBatchSize {
+			if err := ctx.Err(); err != nil {
+				return imported, err
+			}
+			if err := sink.Write(ctx, batch); err != nil {
+				return imported, err
+			}
+			imported += uint64(len(batch))
+			batch = batch[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return imported, err
+	}
+
+	if len(batch) > 0 {
+		if err := sink.Write(ctx, batch); err != nil {
+			return imported, err
+		}
+		imported += uint64(len(batch))
+	}
+	return imported, nil
+}
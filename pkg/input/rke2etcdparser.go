@@ -2,11 +2,10 @@ package input
 
 import (
 	"encoding/json"
-	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
+	"github.com/dbason/opni-supportagent/pkg/errors"
 	"github.com/dbason/opni-supportagent/pkg/util"
 )
 
@@ -16,7 +15,15 @@ const (
 	RFC3339Milli        = "2006-01-02T15:04:05.999Z07:00"
 )
 
-type RKE2EtcdParser struct{}
+type RKE2EtcdParser struct {
+	timestamp *timestampMatcher
+}
+
+func NewRKE2EtcdParser() *RKE2EtcdParser {
+	return &RKE2EtcdParser{
+		timestamp: newTimestampMatcher(EtcdTimestampRegex),
+	}
+}
 
 type EtcdJSONLog struct {
 	LogLevel  string `json:"level,omitempty"`
@@ -24,25 +31,26 @@ type EtcdJSONLog struct {
 	Message   string `json:"msg,omitempty"`
 }
 
-func (r RKE2EtcdParser) ParseTimestamp(log string) (time.Time, string, bool) {
+func (r *RKE2EtcdParser) ParseTimestamp(log string) (time.Time, string, bool, error) {
 	if strings.HasPrefix(log, "{") {
 		jsonLog := &EtcdJSONLog{}
-		json.Unmarshal([]byte(log), jsonLog)
+		if err := json.Unmarshal([]byte(log), jsonLog); err != nil {
+			return time.Time{}, log, false, errors.ErrInvalidTimestampWithValue(log, err.Error())
+		}
 		datetime, err := time.Parse(RFC3339Milli, jsonLog.Timestamp)
 		if err != nil {
-			util.Log.Panic(err)
+			return time.Time{}, log, false, errors.ErrInvalidTimestampWithValue(jsonLog.Timestamp, err.Error())
 		}
-		return datetime, log, true
+		return datetime, log, true, nil
 	}
-	re := regexp.MustCompile(EtcdTimestampRegex)
-	datestring := re.FindString(log)
+	datestring := r.timestamp.findString(log)
 	if len(datestring) == 0 {
 		util.Log.Warnf("no date found in log: %s", log)
-		return time.Now(), log, false
+		return time.Now(), log, false, nil
 	}
-	datetime, err := time.Parse(EtcdTimestampLayout, fmt.Sprintf("%sZ", datestring))
+	datetime, err := time.Parse(EtcdTimestampLayout, datestring+"Z")
 	if err != nil {
-		util.Log.Panic(err)
+		return time.Time{}, log, false, errors.ErrInvalidTimestampWithValue(datestring, err.Error())
 	}
-	return datetime, log, true
+	return datetime, log, true, nil
 }
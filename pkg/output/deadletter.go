@@ -0,0 +1,107 @@
+package output
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"github.com/dbason/opni-supportagent/pkg/util"
+)
+
+// DeadLetterFile collects the logs a sink failed to store in an NDJSON file
+// so they can be sent again with retry-failed.  The file is only created
+// once the first log fails.
+type DeadLetterFile struct {
+	path string
+
+	mu          sync.Mutex
+	sink        *FileSink
+	byComponent map[string]uint64
+	total       uint64
+}
+
+func NewDeadLetterFile(path string) *DeadLetterFile {
+	return &DeadLetterFile{
+		path:        path,
+		byComponent: map[string]uint64{},
+	}
+}
+
+// Add writes the log to the file.  It is safe to call from the callbacks of
+// the sinks.
+func (d *DeadLetterFile) Add(log input.LogMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.total++
+	d.byComponent[failureComponent(log)]++
+
+	if d.sink == nil {
+		sink, err := NewFileSink(d.path)
+		if err != nil {
+			util.Log.Errorf("unable to create failed logs file: %s", err)
+			return
+		}
+		d.sink = sink
+	}
+	if err := d.sink.Write(context.Background(), []input.LogMessage{log}); err != nil {
+		util.Log.Errorf("unable to write failed log: %s", err)
+	}
+}
+
+// Path returns the path of the file.
+func (d *DeadLetterFile) Path() string {
+	return d.path
+}
+
+// Total returns the number of logs added.
+func (d *DeadLetterFile) Total() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.total
+}
+
+// Report logs the number of failed logs for each component.
+func (d *DeadLetterFile) Report() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.total == 0 {
+		return
+	}
+	components := make([]string, 0, len(d.byComponent))
+	for component := range d.byComponent {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	util.Log.Warnf("%d logs failed to publish:", d.total)
+	for _, component := range components {
+		util.Log.Warnf("  %s: %d", component, d.byComponent[component])
+	}
+}
+
+func (d *DeadLetterFile) Close(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.sink == nil {
+		return nil
+	}
+	return d.sink.Close(ctx)
+}
+
+// failureComponent names the component a log came from for the report.  Logs
+// without a kubernetes component are named after their log type.
+func failureComponent(log input.LogMessage) string {
+	name := log.Component
+	if name == "" {
+		name = string(log.LogType)
+	}
+	if log.NodeName != "" {
+		name = log.NodeName + "/" + name
+	}
+	return name
+}
@@ -0,0 +1,100 @@
+package output
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/dbason/opni-supportagent/pkg/input"
+)
+
+// FileSink writes logs to a file as newline delimited JSON, with one
+// document per line exactly as it would have been indexed.  Files ending in
+// .gz are gzip compressed.
+type FileSink struct {
+	mu     sync.Mutex
+	file   *os.File
+	gzip   *gzip.Writer
+	writer *bufio.Writer
+	stats  input.SinkStats
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &FileSink{
+		file: file,
+	}
+	var w io.Writer = file
+	if strings.HasSuffix(path, ".gz") {
+		sink.gzip = gzip.NewWriter(file)
+		w = sink.gzip
+	}
+	sink.writer = bufio.NewWriter(w)
+	return sink, nil
+}
+
+func (s *FileSink) Write(_ context.Context, logs []input.LogMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	encoder := json.NewEncoder(s.writer)
+	for _, log := range logs {
+		s.stats.NumAdded++
+		if err := encoder.Encode(log); err != nil {
+			s.stats.NumFailed++
+			return err
+		}
+		s.stats.NumFlushed++
+	}
+	return nil
+}
+
+func (s *FileSink) Flush(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.flush()
+}
+
+func (s *FileSink) flush() error {
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	if s.gzip != nil {
+		return s.gzip.Flush()
+	}
+	return nil
+}
+
+func (s *FileSink) Stats() input.SinkStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
+
+func (s *FileSink) Close(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	if s.gzip != nil {
+		if err := s.gzip.Close(); err != nil {
+			s.file.Close()
+			return err
+		}
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,69 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/dbason/opni-supportagent/pkg/errors"
+	"github.com/dbason/opni-supportagent/pkg/util"
+)
+
+func newHTTPClient() *http.Client {
+	// Set sane transport timeouts
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Dial = (&net.Dialer{
+		Timeout: 5 * time.Second,
+	}).Dial
+	transport.TLSHandshakeTimeout = 5 * time.Second
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Minute,
+	}
+}
+
+// postWithRetry posts the JSON payload to the URL, retrying with backoff if
+// the server is unavailable or asks us to slow down.
+func postWithRetry(ctx context.Context, client *http.Client, url string, data []byte) error {
+	retryBackoff := backoff.NewExponentialBackOff()
+	retryBackoff.InitialInterval = 2 * time.Second
+
+	return backoff.RetryNotify(
+		func() error {
+			return post(ctx, client, url, data)
+		},
+		backoff.WithContext(retryBackoff, ctx),
+		func(err error, next time.Duration) {
+			util.Log.Warnf("retrying payload in %s: %s", next, err)
+		},
+	)
+}
+
+func post(ctx context.Context, client *http.Client, url string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return backoff.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return errors.ErrPayloadRejectedWithResp(resp.Status, string(body))
+	default:
+		return backoff.Permanent(errors.ErrPayloadRejectedWithResp(resp.Status, string(body)))
+	}
+}
@@ -0,0 +1,173 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"github.com/dbason/opni-supportagent/pkg/util"
+)
+
+const (
+	lokiBatchSize = 1000
+	lokiPushPath  = "/loki/api/v1/push"
+)
+
+// LokiSink pushes logs to Loki.  The component, node, case, log type and file
+// of each log become the labels of its stream.  Each file is read in order by
+// a single worker, so labelling streams with the file keeps the entries of
+// every stream in order across batches, as Loki requires.
+type LokiSink struct {
+	url    string
+	client *http.Client
+
+	mu       sync.Mutex
+	pending  []input.LogMessage
+	stats    input.SinkStats
+	onStored logCallbacks
+	onFailed logCallbacks
+}
+
+type lokiPushRequest struct {
+	Streams []*lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	// Values are pairs of the timestamp in nanoseconds and the log line.
+	Values [][2]string `json:"values"`
+}
+
+func NewLokiSink(url string) *LokiSink {
+	return &LokiSink{
+		url:     strings.TrimSuffix(url, "/") + lokiPushPath,
+		client:  newHTTPClient(),
+		pending: make([]input.LogMessage, 0, lokiBatchSize),
+	}
+}
+
+func (s *LokiSink) Write(ctx context.Context, logs []input.LogMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, log := range logs {
+		s.stats.NumAdded++
+		s.pending = append(s.pending, log)
+		if len(s.pending) == lokiBatchSize {
+			if err := s.push(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *LokiSink) OnStored(fn func(log input.LogMessage)) {
+	s.onStored.add(fn)
+}
+
+func (s *LokiSink) OnFailed(fn func(log input.LogMessage)) {
+	s.onFailed.add(fn)
+}
+
+func (s *LokiSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.push(ctx)
+}
+
+func (s *LokiSink) Stats() input.SinkStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
+
+func (s *LokiSink) Close(ctx context.Context) error {
+	return s.Flush(ctx)
+}
+
+// push sends the pending logs grouped into streams.  Loki requires the
+// entries of each stream to be in order so they are sorted by time first, as
+// the logs of a file can be out of order where its lines are.
+func (s *LokiSink) push(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	defer func() {
+		s.pending = s.pending[:0]
+	}()
+
+	sort.SliceStable(s.pending, func(i, j int) bool {
+		return s.pending[i].Timestamp.Before(s.pending[j].Timestamp)
+	})
+
+	streams := map[string]*lokiStream{}
+	request := lokiPushRequest{}
+	for _, log := range s.pending {
+		labels := lokiLabels(log)
+		key := labelsKey(labels)
+		stream, ok := streams[key]
+		if !ok {
+			stream = &lokiStream{
+				Stream: labels,
+			}
+			streams[key] = stream
+			request.Streams = append(request.Streams, stream)
+		}
+		stream.Values = append(stream.Values, [2]string{
+			strconv.FormatInt(log.Timestamp.UnixNano(), 10),
+			log.Log,
+		})
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	err = postWithRetry(ctx, s.client, s.url, data)
+	if err != nil {
+		util.Log.Errorf("failed to push %d logs: %s", len(s.pending), err)
+		s.stats.NumFailed += uint64(len(s.pending))
+		for _, log := range s.pending {
+			s.onFailed.call(log)
+		}
+		return nil
+	}
+	s.stats.NumFlushed += uint64(len(s.pending))
+	for _, log := range s.pending {
+		s.onStored.call(log)
+	}
+	return nil
+}
+
+func lokiLabels(log input.LogMessage) map[string]string {
+	labels := map[string]string{
+		"cluster_id": log.ClusterID,
+		"node_name":  log.NodeName,
+		"log_type":   string(log.LogType),
+	}
+	if log.Component != "" {
+		labels["component"] = log.Component
+	}
+	if log.BundleFile != "" {
+		labels["filename"] = log.BundleFile
+	}
+	return labels
+}
+
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key, value := range labels {
+		keys = append(keys, key+"="+strconv.Quote(value))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
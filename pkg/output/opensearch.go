@@ -0,0 +1,303 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"github.com/dbason/opni-supportagent/pkg/util"
+	"github.com/opensearch-project/opensearch-go"
+	"github.com/opensearch-project/opensearch-go/opensearchtransport"
+	"github.com/opensearch-project/opensearch-go/opensearchutil"
+)
+
+const (
+	logsIndex = "logs"
+)
+
+// OpensearchSink bulk indexes logs into the logs index of an Opensearch
+// cluster.  Logs are indexed with their document ID so publishing the same
+// logs again doesn't duplicate them.
+type OpensearchSink struct {
+	*opensearch.Client
+	config OpensearchSinkConfig
+
+	// mu is held for writing while the indexer is started or flushed, and for
+	// reading while logs are added to it.
+	mu      sync.RWMutex
+	indexer opensearchutil.BulkIndexer
+	// stats holds the totals of the indexers that have already been closed.
+	stats input.SinkStats
+	// resultsMu guards the logs waiting for a result and the failed and
+	// skipped counts, so they are always read together.
+	resultsMu sync.Mutex
+	// pending holds the logs added to the indexer, by document ID, until
+	// Opensearch reports whether each was stored.  When a whole bulk request
+	// fails no item is reported, so the logs left when the indexer is closed
+	// are failed.
+	pending map[string]input.LogMessage
+	// failed counts the logs that weren't stored.  The bulk indexer's own
+	// count includes the skipped logs and misses bulk responses it can't
+	// decode, so it isn't used.
+	failed uint64
+	// skipped counts the logs that already existed when SkipExisting is set.
+	// The bulk indexer counts these as failed.
+	skipped  uint64
+	onStored logCallbacks
+	onFailed logCallbacks
+}
+
+type OpensearchSinkConfig struct {
+	// SkipExisting creates logs instead of indexing them so logs that have
+	// already been published are left as they are.
+	SkipExisting bool
+	// NumWorkers is the number of bulk requests sent at the same time.
+	// Defaults to the number of CPUs.
+	NumWorkers int
+	// FlushBytes is the size of each bulk request.  Defaults to 5MB.
+	FlushBytes int
+	// LogRequests logs every request sent to Opensearch.
+	LogRequests bool
+}
+
+func NewOpensearchSink(
+	opensearchURL string,
+	username string,
+	password string,
+	config OpensearchSinkConfig,
+) (*OpensearchSink, error) {
+	// Set sane transport timeouts
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Dial = (&net.Dialer{
+		Timeout: 5 * time.Second,
+	}).Dial
+	transport.TLSHandshakeTimeout = 5 * time.Second
+
+	retryBackoff := backoff.NewExponentialBackOff()
+	retryBackoff.InitialInterval = 2 * time.Second
+
+	osCfg := opensearch.Config{
+		Addresses: []string{
+			opensearchURL,
+		},
+		Username:             username,
+		Password:             password,
+		UseResponseCheckOnly: true,
+		Transport:            transport,
+		RetryOnStatus:        []int{502, 503, 504, 429},
+		RetryBackoff: func(i int) time.Duration {
+			if i == 1 {
+				retryBackoff.Reset()
+			}
+			util.Log.Warnf("retrying operation, retry %d", i)
+			return retryBackoff.NextBackOff()
+		},
+	}
+	if config.LogRequests {
+		osCfg.Logger = &opensearchtransport.ColorLogger{Output: os.Stdout}
+	}
+
+	osClient, err := opensearch.NewClient(osCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OpensearchSink{
+		Client:  osClient,
+		config:  config,
+		pending: map[string]input.LogMessage{},
+	}, nil
+}
+
+func (s *OpensearchSink) Write(ctx context.Context, logs []input.LogMessage) error {
+	action := "index"
+	if s.config.SkipExisting {
+		action = "create"
+	}
+
+	// Logs from several files are added at once, the indexer may be flushed
+	// between starting it and adding to it so check it is still running.
+	for {
+		s.mu.RLock()
+		if s.indexer != nil {
+			break
+		}
+		s.mu.RUnlock()
+		if err := s.startIndexer(); err != nil {
+			return err
+		}
+	}
+	defer s.mu.RUnlock()
+
+	for _, log := range logs {
+		log := log
+		data, err := json.Marshal(log)
+		if err != nil {
+			util.Log.Error("could not encode log to json")
+			continue
+		}
+		documentID := log.DocumentID()
+		s.addPending(documentID, log)
+		err = s.indexer.Add(
+			ctx,
+			opensearchutil.BulkIndexerItem{
+				Action:     action,
+				DocumentID: documentID,
+				Body:       bytes.NewReader(data),
+				OnSuccess: func(ctx context.Context, item opensearchutil.BulkIndexerItem, res opensearchutil.BulkIndexerResponseItem) {
+					s.removePending(documentID)
+					s.onStored.call(log)
+				},
+				OnFailure: func(ctx context.Context, item opensearchutil.BulkIndexerItem, res opensearchutil.BulkIndexerResponseItem, err error) {
+					switch {
+					case err != nil:
+						util.Log.Errorf("%s", err)
+					case res.Status == http.StatusConflict && s.config.SkipExisting:
+						s.skipLog(documentID)
+						s.onStored.call(log)
+						return
+					default:
+						util.Log.Errorf("%d - %s: %s", res.Status, res.Error.Type, res.Error.Reason)
+					}
+					s.failLog(documentID, log)
+				},
+			},
+		)
+		if err != nil {
+			s.removePending(documentID)
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *OpensearchSink) addPending(documentID string, log input.LogMessage) {
+	s.resultsMu.Lock()
+	defer s.resultsMu.Unlock()
+
+	s.pending[documentID] = log
+}
+
+func (s *OpensearchSink) removePending(documentID string) {
+	s.resultsMu.Lock()
+	defer s.resultsMu.Unlock()
+
+	delete(s.pending, documentID)
+}
+
+// skipLog counts a log that Opensearch already had.
+func (s *OpensearchSink) skipLog(documentID string) {
+	s.resultsMu.Lock()
+	defer s.resultsMu.Unlock()
+
+	delete(s.pending, documentID)
+	s.skipped++
+}
+
+// failLog counts a log Opensearch didn't store and reports it.
+func (s *OpensearchSink) failLog(documentID string, log input.LogMessage) {
+	s.resultsMu.Lock()
+	delete(s.pending, documentID)
+	s.failed++
+	s.resultsMu.Unlock()
+
+	s.onFailed.call(log)
+}
+
+// failPending fails the logs that Opensearch never reported on, and returns
+// how many there were.
+func (s *OpensearchSink) failPending() int {
+	s.resultsMu.Lock()
+	pending := s.pending
+	s.pending = map[string]input.LogMessage{}
+	s.failed += uint64(len(pending))
+	s.resultsMu.Unlock()
+
+	for _, log := range pending {
+		s.onFailed.call(log)
+	}
+	return len(pending)
+}
+
+// startIndexer starts a bulk indexer if there isn't one running.  The bulk
+// indexer can't be reused once it has been closed so a new one is started
+// after each flush.
+func (s *OpensearchSink) startIndexer() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexer != nil {
+		return nil
+	}
+	indexer, err := opensearchutil.NewBulkIndexer(opensearchutil.BulkIndexerConfig{
+		Index:      logsIndex,
+		Client:     s.Client,
+		NumWorkers: s.config.NumWorkers,
+		FlushBytes: s.config.FlushBytes,
+		OnError: func(ctx context.Context, err error) {
+			util.Log.Errorf("bulk request failed: %s", err)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	s.indexer = indexer
+	return nil
+}
+
+func (s *OpensearchSink) OnStored(fn func(log input.LogMessage)) {
+	s.onStored.add(fn)
+}
+
+func (s *OpensearchSink) OnFailed(fn func(log input.LogMessage)) {
+	s.onFailed.add(fn)
+}
+
+// Flush sends the logs added so far.  The logs of bulk requests that failed
+// as a whole are counted as failed like any other, so the failure threshold
+// decides whether the publish fails.
+func (s *OpensearchSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexer == nil {
+		return nil
+	}
+	err := s.indexer.Close(ctx)
+	stats := s.indexer.Stats()
+	s.stats.NumAdded += stats.NumAdded
+	s.stats.NumFlushed += stats.NumFlushed
+	s.indexer = nil
+	if failed := s.failPending(); failed > 0 {
+		util.Log.Errorf("%d logs were not stored as their bulk requests failed", failed)
+	}
+	return err
+}
+
+func (s *OpensearchSink) Stats() input.SinkStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := s.stats
+	if s.indexer != nil {
+		current := s.indexer.Stats()
+		stats.NumAdded += current.NumAdded
+		stats.NumFlushed += current.NumFlushed
+	}
+	s.resultsMu.Lock()
+	stats.NumFailed = s.failed
+	stats.NumSkipped = s.skipped
+	s.resultsMu.Unlock()
+	return stats
+}
+
+func (s *OpensearchSink) Close(ctx context.Context) error {
+	return s.Flush(ctx)
+}
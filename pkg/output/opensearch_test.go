@@ -0,0 +1,159 @@
+package output
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/input"
+)
+
+// newTestOpensearchSink returns a sink that sends its bulk requests to
+// handler, and the logs it reports as stored and failed.
+func newTestOpensearchSink(t *testing.T, config OpensearchSinkConfig, handler http.HandlerFunc) (*OpensearchSink, *loggedLogs, *loggedLogs) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	sink, err := NewOpensearchSink(server.URL, "admin", "admin", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := &loggedLogs{}
+	failed := &loggedLogs{}
+	sink.OnStored(stored.add)
+	sink.OnFailed(failed.add)
+	return sink, stored, failed
+}
+
+type loggedLogs struct {
+	mu   sync.Mutex
+	logs []input.LogMessage
+}
+
+func (l *loggedLogs) add(log input.LogMessage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.logs = append(l.logs, log)
+}
+
+func (l *loggedLogs) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.logs)
+}
+
+func testLogs(count int) []input.LogMessage {
+	logs := make([]input.LogMessage, count)
+	for i := range logs {
+		logs[i] = input.LogMessage{
+			Timestamp:    time.Date(2022, time.January, 2, 15, 4, 5, i, time.UTC),
+			Log:          "log",
+			ClusterID:    "case",
+			BundleFile:   "log",
+			BundleOffset: int64(i),
+		}
+	}
+	return logs
+}
+
+func TestOpensearchSinkBulkRequestFails(t *testing.T) {
+	sink, stored, failed := newTestOpensearchSink(t, OpensearchSinkConfig{}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"boom"}`))
+	})
+	deadLetter := NewDeadLetterFile(filepath.Join(t.TempDir(), "failed.ndjson"))
+	sink.OnFailed(deadLetter.Add)
+
+	if err := sink.Write(context.Background(), testLogs(3)); err != nil {
+		t.Fatalf("Write error: %s", err)
+	}
+	// The failed request is left to the failure threshold
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush error = %s, want nil", err)
+	}
+	if err := deadLetter.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := sink.Stats()
+	if stats.NumAdded != 3 || stats.NumFlushed != 0 || stats.NumFailed != 3 {
+		t.Errorf("stats = %+v, want 3 added and 3 failed", stats)
+	}
+	if stored.len() != 0 || failed.len() != 3 {
+		t.Errorf("%d logs stored and %d failed, want 0 and 3", stored.len(), failed.len())
+	}
+	if deadLetter.Total() != 3 {
+		t.Errorf("%d logs written to the failed logs file, want 3", deadLetter.Total())
+	}
+}
+
+// bulkItem is the action line of a bulk request, or an item of its response.
+type bulkItem map[string]struct {
+	ID     string            `json:"_id"`
+	Status int               `json:"status,omitempty"`
+	Error  map[string]string `json:"error,omitempty"`
+}
+
+func TestOpensearchSinkSkipExisting(t *testing.T) {
+	logs := testLogs(3)
+	existing := map[string]bool{
+		logs[0].DocumentID(): true,
+		logs[2].DocumentID(): true,
+	}
+	sink, stored, failed := newTestOpensearchSink(t, OpensearchSinkConfig{SkipExisting: true}, func(w http.ResponseWriter, r *http.Request) {
+		response := struct {
+			Errors bool       `json:"errors"`
+			Items  []bulkItem `json:"items"`
+		}{}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var action bulkItem
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+				t.Errorf("bulk action %q isn't JSON: %s", scanner.Text(), err)
+			}
+			// Skip the document
+			scanner.Scan()
+			create, ok := action["create"]
+			if !ok {
+				t.Errorf("bulk action = %v, want create", action)
+			}
+			create.Status = http.StatusCreated
+			if existing[create.ID] {
+				response.Errors = true
+				create.Status = http.StatusConflict
+				create.Error = map[string]string{
+					"type":   "version_conflict_engine_exception",
+					"reason": "document already exists",
+				}
+			}
+			response.Items = append(response.Items, bulkItem{"create": create})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+
+	if err := sink.Write(context.Background(), logs); err != nil {
+		t.Fatalf("Write error: %s", err)
+	}
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush error: %s", err)
+	}
+
+	stats := sink.Stats()
+	if stats.NumFlushed != 1 || stats.NumSkipped != 2 || stats.NumFailed != 0 {
+		t.Errorf("stats = %+v, want 1 flushed, 2 skipped and none failed", stats)
+	}
+	// Logs that already exist are stored as far as the checkpoint is concerned
+	if stored.len() != 3 || failed.len() != 0 {
+		t.Errorf("%d logs stored and %d failed, want 3 and 0", stored.len(), failed.len())
+	}
+}
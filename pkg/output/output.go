@@ -0,0 +1,71 @@
+package output
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+	"github.com/dbason/opni-supportagent/pkg/input"
+)
+
+const (
+	fileScheme = "file://"
+	// opniSchemePrefix is added to the scheme of a payload receiver URL, e.g.
+	// opni+https://payload-receiver.example.com
+	opniSchemePrefix = "opni+"
+	// lokiSchemePrefix is added to the scheme of the Loki URL, e.g.
+	// loki+https://loki.example.com
+	lokiSchemePrefix = "loki+"
+)
+
+// NewSink returns the sink for the output URL.  An empty output publishes
+// to the Opensearch endpoint.
+func NewSink(
+	outputURL string,
+	endpoint string,
+	username string,
+	password string,
+	config OpensearchSinkConfig,
+) (input.Sink, error) {
+	switch {
+	case outputURL == "":
+		return NewOpensearchSink(endpoint, username, password, config)
+	case strings.HasPrefix(outputURL, fileScheme):
+		return NewFileSink(strings.TrimPrefix(outputURL, fileScheme))
+	case strings.HasPrefix(outputURL, opniSchemePrefix):
+		return NewPayloadReceiverSink(strings.TrimPrefix(outputURL, opniSchemePrefix)), nil
+	case strings.HasPrefix(outputURL, lokiSchemePrefix):
+		return NewLokiSink(strings.TrimPrefix(outputURL, lokiSchemePrefix)), nil
+	default:
+		return nil, errors.ErrUnknownOutputWithURL(outputURL)
+	}
+}
+
+// RequiresPassword returns whether the output needs the Opensearch password.
+func RequiresPassword(outputURL string) bool {
+	return outputURL == ""
+}
+
+// logCallbacks are the functions registered with OnStored or OnFailed.  Each
+// is called in turn, so the checkpoint, the failed logs and the progress can
+// all follow what happens to the logs.
+type logCallbacks struct {
+	mu  sync.RWMutex
+	fns []func(input.LogMessage)
+}
+
+func (c *logCallbacks) add(fn func(input.LogMessage)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.fns = append(c.fns, fn)
+}
+
+func (c *logCallbacks) call(log input.LogMessage) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, fn := range c.fns {
+		fn(log)
+	}
+}
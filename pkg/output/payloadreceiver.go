@@ -0,0 +1,110 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"github.com/dbason/opni-supportagent/pkg/util"
+)
+
+const (
+	payloadBatchSize = 1000
+)
+
+// PayloadReceiverSink posts logs to the payload receiver of an Opni cluster
+// so they go through the same preprocessing as logs shipped from a cluster.
+// The payload receiver accepts a JSON array of logs in each request.
+type PayloadReceiverSink struct {
+	url    string
+	client *http.Client
+
+	mu       sync.Mutex
+	pending  []input.LogMessage
+	stats    input.SinkStats
+	onStored logCallbacks
+	onFailed logCallbacks
+}
+
+func NewPayloadReceiverSink(url string) *PayloadReceiverSink {
+	return &PayloadReceiverSink{
+		url:     url,
+		client:  newHTTPClient(),
+		pending: make([]input.LogMessage, 0, payloadBatchSize),
+	}
+}
+
+func (s *PayloadReceiverSink) Write(ctx context.Context, logs []input.LogMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, log := range logs {
+		s.stats.NumAdded++
+		s.pending = append(s.pending, log)
+		if len(s.pending) == payloadBatchSize {
+			if err := s.post(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *PayloadReceiverSink) OnStored(fn func(log input.LogMessage)) {
+	s.onStored.add(fn)
+}
+
+func (s *PayloadReceiverSink) OnFailed(fn func(log input.LogMessage)) {
+	s.onFailed.add(fn)
+}
+
+func (s *PayloadReceiverSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.post(ctx)
+}
+
+func (s *PayloadReceiverSink) Stats() input.SinkStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
+
+func (s *PayloadReceiverSink) Close(ctx context.Context) error {
+	return s.Flush(ctx)
+}
+
+// post sends the pending logs, retrying with backoff if the payload receiver
+// is unavailable.  Logs that still can't be sent are counted as failed.
+func (s *PayloadReceiverSink) post(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	defer func() {
+		s.pending = s.pending[:0]
+	}()
+
+	data, err := json.Marshal(s.pending)
+	if err != nil {
+		return err
+	}
+
+	err = postWithRetry(ctx, s.client, s.url, data)
+	if err != nil {
+		util.Log.Errorf("failed to send %d logs: %s", len(s.pending), err)
+		s.stats.NumFailed += uint64(len(s.pending))
+		for _, log := range s.pending {
+			s.onFailed.call(log)
+		}
+		return nil
+	}
+	s.stats.NumFlushed += uint64(len(s.pending))
+	for _, log := range s.pending {
+		s.onStored.call(log)
+	}
+	return nil
+}
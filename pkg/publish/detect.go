@@ -0,0 +1,87 @@
+package publish
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+	"github.com/dbason/opni-supportagent/pkg/util"
+)
+
+type Distribution string
+
+const (
+	RKE  Distribution = "rke"
+	RKE2 Distribution = "rke2"
+	K3S  Distribution = "k3s"
+
+	// MinDetectionConfidence is the confidence required before the detected
+	// distribution is trusted.
+	MinDetectionConfidence = 0.5
+)
+
+func (d Distribution) Valid() bool {
+	switch d {
+	case RKE, RKE2, K3S:
+		return true
+	default:
+		return false
+	}
+}
+
+// DetectDistribution inspects the bundle and returns
+// the distribution it was most likely collected from.  Each embedded layout
+// is scored by the fraction of its markers found in the bundle and the
+// returned confidence is the share of the total score held by the best match.
+func DetectDistribution(bundle fs.FS) (Distribution, float64, error) {
+	entries, err := layouts.ReadDir("layouts")
+	if err != nil {
+		return "", 0, err
+	}
+
+	var (
+		best      Distribution
+		bestScore float64
+		total     float64
+	)
+	for _, entry := range entries {
+		distribution := Distribution(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+		layout, err := LoadLayout(distribution, "")
+		if err != nil {
+			return "", 0, err
+		}
+		score, err := layout.markerScore(bundle)
+		if err != nil {
+			return "", 0, err
+		}
+		util.Log.Debugf("%s bundle score %.2f", distribution, score)
+		total += score
+		if score > bestScore {
+			best = distribution
+			bestScore = score
+		}
+	}
+
+	if total == 0 {
+		return "", 0, errors.ErrUnknownDist
+	}
+	return best, bestScore / total, nil
+}
+
+func (l *Layout) markerScore(bundle fs.FS) (float64, error) {
+	if len(l.Markers) == 0 {
+		return 0, nil
+	}
+	var found int
+	for _, marker := range l.Markers {
+		matches, err := fs.Glob(bundle, marker)
+		if err != nil {
+			return 0, err
+		}
+		if len(matches) > 0 {
+			found++
+		}
+	}
+	return float64(found) / float64(len(l.Markers)), nil
+}
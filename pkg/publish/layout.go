@@ -0,0 +1,84 @@
+package publish
+
+import (
+	"embed"
+	"fmt"
+	"os"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed layouts/*.yaml
+var layouts embed.FS
+
+// Layout describes where each component's logs live inside a log collector
+// bundle and how they should be parsed.
+type Layout struct {
+	Distribution Distribution `json:"distribution"`
+	// Markers are paths, or globs, that identify bundles from the
+	// distribution.
+	Markers    []string          `json:"markers,omitempty"`
+	Components []ComponentLayout `json:"components"`
+}
+
+type ComponentLayout struct {
+	// Name is used to refer to the component in the agent output.
+	Name string `json:"name"`
+	// Component is stored as the kubernetes_component of every log.  It may be
+	// left empty for logs that don't belong to a kubernetes component.
+	Component string        `json:"component,omitempty"`
+	Paths     []string      `json:"paths"`
+	Parser    string        `json:"parser"`
+	LogType   input.LogType `json:"logType"`
+	// Required components fail the publish if none of their paths exist.
+	Required bool `json:"required,omitempty"`
+}
+
+// LoadLayout returns the layout for the distribution.  If layoutFile is set
+// it is used instead of the layout embedded in the agent.
+func LoadLayout(distribution Distribution, layoutFile string) (*Layout, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if layoutFile != "" {
+		data, err = os.ReadFile(layoutFile)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		data, err = layouts.ReadFile(fmt.Sprintf("layouts/%s.yaml", distribution))
+		if err != nil {
+			return nil, errors.ErrInvalidDist
+		}
+	}
+
+	layout := &Layout{}
+	if err := yaml.UnmarshalStrict(data, layout); err != nil {
+		return nil, errors.ErrInvalidLayoutWithReason(err.Error())
+	}
+
+	return layout, layout.validate()
+}
+
+func (l *Layout) validate() error {
+	for _, component := range l.Components {
+		if component.Name == "" {
+			return errors.ErrInvalidLayoutWithReason("component name is required")
+		}
+		if len(component.Paths) == 0 {
+			return errors.ErrInvalidLayoutWithReason(fmt.Sprintf("%s has no paths", component.Name))
+		}
+		if _, ok := parsers[component.Parser]; !ok {
+			return errors.ErrInvalidLayoutWithReason(fmt.Sprintf("%s has unknown parser %q", component.Name, component.Parser))
+		}
+		switch component.LogType {
+		case input.LogTypeControlplane, input.LogTypeRancher:
+		default:
+			return errors.ErrInvalidLayoutWithReason(fmt.Sprintf("%s has unknown log type %q", component.Name, component.LogType))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package publish
+
+import (
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/input"
+)
+
+// bundleDate holds when the bundle was collected, in the timezone of the
+// node.  Some log formats don't include the year or timezone so they are
+// filled in from the bundle.
+type bundleDate struct {
+	captured time.Time
+}
+
+// parsers maps the parser names used in the layouts to the parser they
+// construct.  Parsers for logs that can be written by klog split the klog
+// header from the message.
+var parsers = map[string]func(bundleDate) input.DateParser{
+	"docker-etcd": func(bundleDate) input.DateParser {
+		return input.NewDockerParser(input.EtcdRegex, input.EtcdJSONRegex)
+	},
+	"docker-klog": func(bundleDate) input.DateParser {
+		return input.NewKlogParser(input.NewDockerParser(input.KlogRegex))
+	},
+	"docker-rancher": func(bundleDate) input.DateParser {
+		return input.NewKlogParser(input.NewDockerParser(input.RancherRegex, input.KlogRegex))
+	},
+	"rke2-etcd": func(bundleDate) input.DateParser {
+		return input.NewRKE2EtcdParser()
+	},
+	"cri-etcd": func(bundleDate) input.DateParser {
+		return input.NewCRIParser(input.EtcdRegex, input.EtcdJSONRegex)
+	},
+	"cri-klog": func(bundleDate) input.DateParser {
+		return input.NewKlogParser(input.NewCRIParser(input.KlogRegex))
+	},
+	"cri-rancher": func(bundleDate) input.DateParser {
+		return input.NewKlogParser(input.NewCRIParser(input.RancherRegex, input.KlogRegex))
+	},
+	"klog": func(d bundleDate) input.DateParser {
+		return input.NewKlogParser(input.NewDateZoneParser(d.captured, input.KlogRegex, input.KlogLayout))
+	},
+	"journald": func(d bundleDate) input.DateParser {
+		return input.NewKlogParser(input.NewJournaldParser(d.captured))
+	},
+	"rancher": func(d bundleDate) input.DateParser {
+		return input.NewKlogParser(input.NewMultipleParser(
+			input.Dateformat{
+				DateRegex: input.RancherRegex,
+				Layout:    input.RancherLayout,
+			},
+			input.Dateformat{
+				DateRegex: input.KlogRegex,
+				Layout:    input.KlogLayout,
+				Captured:  d.captured,
+			},
+		))
+	},
+}
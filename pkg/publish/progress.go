@@ -0,0 +1,315 @@
+package publish
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"github.com/dbason/opni-supportagent/pkg/util"
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	// progressInterval is how often the progress is redrawn on a terminal.
+	progressInterval = 500 * time.Millisecond
+	// progressLogInterval is how often the progress is logged when stderr
+	// isn't a terminal.
+	progressLogInterval = 10 * time.Second
+)
+
+// progress shows how far through the bundle a publish is.  On a terminal
+// it is redrawn in place on stderr, otherwise it is logged periodically.
+// While it is drawn, logs written to the same terminal are printed above it.
+type progress struct {
+	sink     input.Sink
+	before   input.SinkStats
+	nodeName string
+	out      io.Writer
+	tty      bool
+	started  time.Time
+	// reports is set if the sink reports which logs were stored and failed,
+	// so they can be counted for each component.
+	reports bool
+
+	components []*componentProgress
+	// files are the components by the path of each of their files.
+	files map[string]*componentProgress
+
+	// mu is held while the progress is drawn.  lines are the lines drawn
+	// last time, so they can be drawn again below a log, and drawn is how
+	// many of them are on the terminal.
+	mu    sync.Mutex
+	lines []string
+	drawn int
+	// restoreLog sends the logs back to where they went before the progress
+	// was drawn.
+	restoreLog func()
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// componentProgress counts what has been read from the files of a component.
+type componentProgress struct {
+	name    string
+	size    int64
+	read    int64
+	logs    uint64
+	flushed uint64
+	failed  uint64
+}
+
+func (c *componentProgress) Read(bytes int64, logs uint64) {
+	atomic.AddInt64(&c.read, bytes)
+	atomic.AddUint64(&c.logs, logs)
+}
+
+func newProgress(sink input.Sink, nodeName string) *progress {
+	return &progress{
+		sink:     sink,
+		before:   sink.Stats(),
+		nodeName: nodeName,
+		out:      os.Stderr,
+		tty:      isTerminal(os.Stderr),
+		files:    map[string]*componentProgress{},
+		stop:     make(chan struct{}),
+	}
+}
+
+func isTerminal(file *os.File) bool {
+	return isatty.IsTerminal(file.Fd()) || isatty.IsCygwinTerminal(file.Fd())
+}
+
+// addComponent starts tracking a component whose files add up to size bytes.
+func (p *progress) addComponent(name string, size int64, files []logFile) *componentProgress {
+	component := &componentProgress{
+		name: name,
+		size: size,
+	}
+	p.components = append(p.components, component)
+	for _, file := range files {
+		p.files[file.path] = component
+	}
+	return component
+}
+
+// stored counts a log the sink has stored against its component.
+func (p *progress) stored(log input.LogMessage) {
+	if component := p.component(log); component != nil {
+		atomic.AddUint64(&component.flushed, 1)
+	}
+}
+
+// failed counts a log the sink couldn't store against its component.
+func (p *progress) failed(log input.LogMessage) {
+	if component := p.component(log); component != nil {
+		atomic.AddUint64(&component.failed, 1)
+	}
+}
+
+// component returns the component the log was read from.  The sink is shared
+// by every node, so logs of other nodes are left out.
+func (p *progress) component(log input.LogMessage) *componentProgress {
+	if log.NodeName != p.nodeName {
+		return nil
+	}
+	return p.files[log.BundleFile]
+}
+
+func (p *progress) start() {
+	p.started = time.Now()
+	if acknowledgingSink, ok := p.sink.(input.AcknowledgingSink); ok {
+		acknowledgingSink.OnStored(p.stored)
+		p.reports = true
+	}
+	if failureReportingSink, ok := p.sink.(input.FailureReportingSink); ok {
+		failureReportingSink.OnFailed(p.failed)
+	}
+
+	interval := progressLogInterval
+	if p.tty {
+		interval = progressInterval
+		// Logs written to the terminal would be drawn over
+		if isTerminal(os.Stdout) {
+			p.restoreLog = util.SetLogOutput(&progressLogWriter{
+				progress: p,
+				out:      os.Stdout,
+			})
+		}
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.show()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// finish stops updating the progress and shows where it ended.
+func (p *progress) finish() {
+	close(p.stop)
+	p.wg.Wait()
+	p.show()
+	if p.restoreLog != nil {
+		p.restoreLog()
+	}
+}
+
+func (p *progress) show() {
+	if p.tty {
+		p.draw()
+		return
+	}
+
+	for _, component := range p.components {
+		read := atomic.LoadInt64(&component.read)
+		// Only log the components that are being read
+		if read == 0 || read >= component.size {
+			continue
+		}
+		util.Log.Infof("progress: %s", p.componentLine(component))
+	}
+	util.Log.Infof("progress: %s", p.totalLine())
+}
+
+// draw replaces the lines drawn last time with the current progress.
+func (p *progress) draw() {
+	// The sink may log while its stats are read, so the lines are worked out
+	// before taking the lock the logs are printed under
+	lines := make([]string, 0, len(p.components)+1)
+	for _, component := range p.components {
+		lines = append(lines, p.componentLine(component))
+	}
+	lines = append(lines, p.totalLine())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lines = lines
+	p.drawLocked()
+}
+
+func (p *progress) drawLocked() {
+	var b strings.Builder
+	if p.drawn > 0 {
+		fmt.Fprintf(&b, "\033[%dA", p.drawn)
+	}
+	for _, line := range p.lines {
+		fmt.Fprintf(&b, "\033[2K%s\n", line)
+	}
+	p.drawn = len(p.lines)
+	fmt.Fprint(p.out, b.String())
+}
+
+// clearLocked removes the lines drawn last time.
+func (p *progress) clearLocked() {
+	if p.drawn > 0 {
+		fmt.Fprintf(p.out, "\033[%dA\033[J", p.drawn)
+		p.drawn = 0
+	}
+}
+
+// progressLogWriter prints logs above the progress so it isn't drawn over
+// them.
+type progressLogWriter struct {
+	progress *progress
+	out      io.Writer
+}
+
+func (w *progressLogWriter) Write(data []byte) (int, error) {
+	w.progress.mu.Lock()
+	defer w.progress.mu.Unlock()
+
+	w.progress.clearLocked()
+	n, err := w.out.Write(data)
+	w.progress.drawLocked()
+	return n, err
+}
+
+func (p *progress) componentLine(component *componentProgress) string {
+	read := atomic.LoadInt64(&component.read)
+	line := fmt.Sprintf("%-20s %s, %d logs parsed",
+		component.name,
+		bytesRead(read, component.size),
+		atomic.LoadUint64(&component.logs),
+	)
+	if p.reports {
+		line += fmt.Sprintf(", %d flushed, %d failed",
+			atomic.LoadUint64(&component.flushed),
+			atomic.LoadUint64(&component.failed),
+		)
+	}
+	return line
+}
+
+func (p *progress) totalLine() string {
+	var read, size int64
+	var logs uint64
+	for _, component := range p.components {
+		read += atomic.LoadInt64(&component.read)
+		size += component.size
+		logs += atomic.LoadUint64(&component.logs)
+	}
+	stats := p.sink.Stats()
+
+	elapsed := time.Since(p.started).Seconds()
+	var bytesPerSecond, logsPerSecond float64
+	if elapsed > 0 {
+		bytesPerSecond = float64(read) / elapsed
+		logsPerSecond = float64(logs) / elapsed
+	}
+	eta := "unknown"
+	switch {
+	case read >= size:
+		eta = "0s"
+	case bytesPerSecond > 0:
+		remaining := time.Duration(float64(size-read) / bytesPerSecond * float64(time.Second))
+		eta = remaining.Round(time.Second).String()
+	}
+
+	return fmt.Sprintf("%-20s %s, %d logs parsed, %d flushed, %d failed, %s/s, %.0f logs/s, ETA %s",
+		"total",
+		bytesRead(read, size),
+		logs,
+		stats.NumFlushed-p.before.NumFlushed,
+		stats.NumFailed-p.before.NumFailed,
+		formatBytes(int64(bytesPerSecond)),
+		logsPerSecond,
+		eta,
+	)
+}
+
+func bytesRead(read int64, size int64) string {
+	percent := 100.0
+	if size > 0 {
+		percent = float64(read) / float64(size) * 100
+	}
+	return fmt.Sprintf("%s / %s (%3.0f%%)", formatBytes(read), formatBytes(size), percent)
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,146 @@
+package publish
+
+import (
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"github.com/dbason/opni-supportagent/pkg/util"
+)
+
+const (
+	rotationCurrent  = "current"
+	rotationPrevious = "previous"
+	previousSuffix   = "-previous"
+	gzipSuffix       = ".gz"
+)
+
+// rotationSuffixRegex matches the suffix logrotate adds to rotated files,
+// either a number or a date.
+var rotationSuffixRegex = regexp.MustCompile(`[.-](\d+|\d{8}(?:-\d+)?)$`)
+
+// logFile is a file of a component and the rotation it is from.
+type logFile struct {
+	path     string
+	rotation string
+}
+
+// rotationPatterns returns the patterns matching the rotations of the files
+// matched by pattern, including the pattern itself.  The globs can also match
+// other files next to the log, such as k3s.json next to k3s, so only the
+// matches isRotation accepts are rotations.
+func rotationPatterns(pattern string) []string {
+	return []string{
+		pattern,
+		pattern + ".[0-9]*",
+		pattern + gzipSuffix,
+		pattern + "-[0-9]*",
+		pattern + previousSuffix,
+		pattern + previousSuffix + ".*",
+	}
+}
+
+// isRotation returns whether the file is a rotation of a file matched by
+// pattern, that is the file with a rotation number or date, a -previous
+// suffix or a .gz suffix added.
+func isRotation(pattern string, file string) bool {
+	name := strings.TrimSuffix(file, gzipSuffix)
+	candidates := []string{name}
+	if matches := rotationSuffixRegex.FindStringIndex(name); matches != nil {
+		candidates = append(candidates, name[:matches[0]])
+	}
+	for _, candidate := range candidates {
+		for _, base := range []string{candidate, strings.TrimSuffix(candidate, previousSuffix)} {
+			if base == file {
+				continue
+			}
+			if ok, _ := path.Match(pattern, base); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findRotations returns the files matching the patterns and their rotations.
+func findRotations(bundle fs.FS, patterns []string) ([]logFile, error) {
+	var files []logFile
+	found := map[string]bool{}
+	rotated := map[string]bool{}
+	for _, pattern := range patterns {
+		for i, rotationPattern := range rotationPatterns(pattern) {
+			matches, err := fs.Glob(bundle, rotationPattern)
+			if err != nil {
+				return nil, err
+			}
+			for _, match := range matches {
+				if found[match] || (i > 0 && !isRotation(pattern, match)) {
+					continue
+				}
+				found[match] = true
+				// Only the pattern itself matches files that aren't rotations
+				rotated[match] = i > 0
+				files = append(files, logFile{path: match})
+			}
+		}
+	}
+
+	for i := range files {
+		files[i].rotation = rotationName(files[i].path, found, rotated[files[i].path])
+	}
+	return files, nil
+}
+
+// rotationName works out the rotation of the file from its name.  Numbers
+// at the end of the name are only taken as the rotation if the file is known
+// to be rotated, or the file without them is in the bundle.
+func rotationName(path string, found map[string]bool, rotated bool) string {
+	name := strings.TrimSuffix(path, gzipSuffix)
+	if strings.HasSuffix(name, previousSuffix) {
+		return rotationPrevious
+	}
+	if matches := rotationSuffixRegex.FindStringSubmatchIndex(name); matches != nil {
+		base := name[:matches[0]]
+		if rotated || found[base] {
+			return name[matches[2]:matches[3]]
+		}
+	}
+	return rotationCurrent
+}
+
+// orderByFirstTimestamp sorts the files by the timestamp of the first log in
+// each, so the rotations of a component are read from oldest to newest.
+// Files without a timestamp are put last.
+func orderByFirstTimestamp(bundle fs.FS, files []logFile, parser func() input.DateParser) []logFile {
+	if len(files) < 2 {
+		return files
+	}
+
+	first := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		datetime, ok, err := input.FirstTimestamp(bundle, file.path, parser())
+		if err != nil {
+			util.Log.Warnf("unable to read %s: %s", file.path, err)
+			continue
+		}
+		if ok {
+			first[file.path] = datetime
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		a, aOK := first[files[i].path]
+		b, bOK := first[files[j].path]
+		switch {
+		case aOK && bOK:
+			return a.Before(b)
+		default:
+			return aOK && !bOK
+		}
+	})
+	return files
+}
@@ -0,0 +1,69 @@
+package publish
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFindRotations(t *testing.T) {
+	bundle := fstest.MapFS{
+		"journald/k3s":                 {},
+		"journald/k3s.1":               {},
+		"journald/k3s.2.gz":            {},
+		"journald/k3s.gz":              {},
+		"journald/k3s-20220101":        {},
+		"journald/k3s.json":            {},
+		"journald/k3s.1x":              {},
+		"journald/k3s-agent":           {},
+		"podlogs/etcd":                 {},
+		"podlogs/etcd-previous":        {},
+		"podlogs/etcd-previous.gz":     {},
+		"podlogs/etcd-previous.backup": {},
+	}
+
+	files, err := findRotations(bundle, []string{"journald/k3s", "podlogs/etcd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, file := range files {
+		got[file.path] = file.rotation
+	}
+	want := map[string]string{
+		"journald/k3s":             rotationCurrent,
+		"journald/k3s.1":           "1",
+		"journald/k3s.2.gz":        "2",
+		"journald/k3s.gz":          rotationCurrent,
+		"journald/k3s-20220101":    "20220101",
+		"podlogs/etcd":             rotationCurrent,
+		"podlogs/etcd-previous":    rotationPrevious,
+		"podlogs/etcd-previous.gz": rotationPrevious,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findRotations = %v, want %v", got, want)
+	}
+}
+
+func TestIsRotation(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{"journald/k3s", "journald/k3s.1", true},
+		{"journald/k3s", "journald/k3s.1.gz", true},
+		{"journald/k3s", "journald/k3s.gz", true},
+		{"var/log/kubelet.log", "var/log/kubelet.log-20220101-1", true},
+		{"podlogs/*-etcd", "podlogs/kube-system-etcd-previous.3", true},
+		{"journald/k3s", "journald/k3s.json", false},
+		{"journald/k3s", "journald/k3s.1.json", false},
+		{"journald/k3s", "journald/k3s-agent", false},
+		{"journald/k3s", "journald/k3s", false},
+	}
+	for _, tt := range tests {
+		if got := isRotation(tt.pattern, tt.file); got != tt.want {
+			t.Errorf("isRotation(%q, %q) = %t, want %t", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
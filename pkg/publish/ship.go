@@ -0,0 +1,528 @@
+package publish
+
+import (
+	"bufio"
+	"context"
+	"io/fs"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dbason/opni-supportagent/pkg/errors"
+	"github.com/dbason/opni-supportagent/pkg/input"
+	"github.com/dbason/opni-supportagent/pkg/util"
+)
+
+const (
+	// DefaultNodeName is the node name used when it isn't given and can't be
+	// found in the bundle.
+	DefaultNodeName = "default-node"
+	// journaldParser is the parser of the logs the hostname is taken from.
+	journaldParser = "journald"
+	// The suffixes of journalctl -o json and -o export output saved next to
+	// the text output of the same journal.
+	journaldJSONSuffix   = ".json"
+	journaldExportSuffix = ".export"
+
+	systemDateFile  = "systeminfo/date"
+	timedatectlFile = "systeminfo/timedatectl"
+	// dateLayout is the layout of the date output, less the timezone.
+	dateLayout = "Mon Jan _2 15:04:05 2006"
+)
+
+var (
+	dateRegex     = regexp.MustCompile(`^([A-Z][a-z]{2} [A-Z][a-z]{2} {1,2}\d{1,2} \d{2}:\d{2}:\d{2}) (\S+) (\d{4})`)
+	timezoneRegex = regexp.MustCompile(`Time zone: (\S+)`)
+	// offsetRegex matches the numeric offsets date prints for timezones
+	// without an abbreviation.
+	offsetRegex = regexp.MustCompile(`^([+-])(\d{2})(\d{2})?$`)
+)
+
+type shipper struct {
+	ctx    context.Context
+	bundle fs.FS
+	sink   input.Sink
+	config ShipConfig
+	date   bundleDate
+
+	mu      sync.Mutex
+	summary *Summary
+}
+
+type ShipConfig struct {
+	ClusterName string
+	NodeName    string
+	// Checkpoint, if set, records the logs that have been stored so an
+	// interrupted publish can be resumed.
+	Checkpoint *input.Checkpoint
+	// Workers is the number of files read at the same time.  Defaults to the
+	// number of CPUs.
+	Workers int
+	// Progress shows how far through the bundle the publish is.
+	Progress bool
+	// UnparsedPolicy is what is done with lines whose timestamp can't be
+	// parsed.
+	UnparsedPolicy input.UnparsedPolicy
+	// MaxLineSize is the longest line read, longer lines are truncated.
+	MaxLineSize int
+	// Timezone, if set, is the timezone of the node, used instead of the
+	// one found in the bundle for logs without a timezone.
+	Timezone *time.Location
+}
+
+// Summary describes what was published from a bundle.
+type Summary struct {
+	Published []string
+	Skipped   []string
+	Flushed   uint64
+	Existing  uint64
+	Failed    uint64
+	Start     time.Time
+	End       time.Time
+	// Unfinished are the files that weren't completely read because the
+	// publish was interrupted or failed.
+	Unfinished []string
+	// Unparsed counts the lines whose timestamp couldn't be parsed.
+	Unparsed input.UnparsedCounts
+	// Truncated counts the lines that were too long to be read in full.
+	Truncated uint64
+}
+
+// shipJob is a single file of a component.
+type shipJob struct {
+	layout   ComponentLayout
+	file     logFile
+	progress input.ProgressReporter
+}
+
+// ShipControlPlane publishes all the components in the layout from the bundle
+// to the sink.  The files of all the components are read concurrently and
+// written to the sink, which is flushed once they have all been read.
+func ShipControlPlane(
+	ctx context.Context,
+	bundle fs.FS,
+	layout *Layout,
+	sink input.Sink,
+	config ShipConfig,
+) (*Summary, error) {
+	if config.Workers <= 0 {
+		config.Workers = runtime.NumCPU()
+	}
+
+	date, err := readBundleDate(bundle, config.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &shipper{
+		ctx:     ctx,
+		bundle:  bundle,
+		sink:    sink,
+		config:  config,
+		date:    date,
+		summary: &Summary{},
+	}
+
+	if s.config.NodeName == DefaultNodeName {
+		if hostname := s.journaldHostname(layout); hostname != "" {
+			util.Log.Infof("using node name %s from the journald logs", hostname)
+			s.config.NodeName = hostname
+		}
+	}
+
+	var progress *progress
+	if config.Progress {
+		progress = newProgress(sink, s.config.NodeName)
+	}
+
+	var jobs []shipJob
+	for _, componentLayout := range layout.Components {
+		files, err := s.componentFiles(componentLayout)
+		if err != nil {
+			return s.summary, err
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		var reporter input.ProgressReporter
+		if progress != nil {
+			size, err := s.filesSize(files)
+			if err != nil {
+				return s.summary, err
+			}
+			reporter = progress.addComponent(componentLayout.Name, size, files)
+		}
+		for _, file := range files {
+			jobs = append(jobs, shipJob{
+				layout:   componentLayout,
+				file:     file,
+				progress: reporter,
+			})
+		}
+	}
+
+	before := sink.Stats()
+	if progress != nil {
+		progress.start()
+	}
+	err = s.run(jobs)
+	// Flush whatever was written even if a file failed or the publish was
+	// interrupted
+	if flushErr := sink.Flush(context.Background()); flushErr != nil && err == nil {
+		err = flushErr
+	}
+	if progress != nil {
+		progress.finish()
+	}
+	after := sink.Stats()
+
+	s.summary.Flushed = after.NumFlushed - before.NumFlushed
+	s.summary.Existing = after.NumSkipped - before.NumSkipped
+	s.summary.Failed = after.NumFailed - before.NumFailed
+	if s.summary.Existing > 0 {
+		util.Log.Infof("%d logs flushed, %d already published, %d failed", s.summary.Flushed, s.summary.Existing, s.summary.Failed)
+	} else {
+		util.Log.Infof("%d logs flushed, %d failed", s.summary.Flushed, s.summary.Failed)
+	}
+	if s.summary.Truncated > 0 {
+		util.Log.Warnf("%d lines were too long and have been truncated", s.summary.Truncated)
+	}
+	if unparsed := s.summary.Unparsed; unparsed.Total() > 0 {
+		util.Log.Warnf("%d lines with timestamps that couldn't be parsed: %d skipped, %d attached to the previous log, %d indexed as unparsed",
+			unparsed.Total(),
+			unparsed.Skipped,
+			unparsed.Attached,
+			unparsed.Indexed,
+		)
+	}
+	if ctx.Err() != nil {
+		util.Log.Warnf("publish interrupted, %d of %d files were not completely read:", len(s.summary.Unfinished), len(jobs))
+		for _, file := range s.summary.Unfinished {
+			util.Log.Warnf("  %s", file)
+		}
+		return s.summary, errors.ErrInterrupted
+	}
+	if err != nil {
+		if len(s.summary.Unfinished) > 0 {
+			util.Log.Warnf("%d of %d files were not completely read:", len(s.summary.Unfinished), len(jobs))
+			for _, file := range s.summary.Unfinished {
+				util.Log.Warnf("  %s", file)
+			}
+		}
+		return s.summary, err
+	}
+
+	if !s.summary.Start.IsZero() {
+		util.Log.Infof("published logs from %s to %s", s.summary.Start.Format(time.RFC3339), s.summary.End.Format(time.RFC3339))
+	}
+	return s.summary, nil
+}
+
+// readBundleDate reads when the bundle was captured from the date output.
+// The timezone is the one given, or else the one timedatectl reported, or
+// else the timezone in the date output if it isn't ambiguous.
+func readBundleDate(bundle fs.FS, location *time.Location) (bundleDate, error) {
+	if location == nil {
+		var err error
+		location, err = readTimedatectl(bundle)
+		if err != nil {
+			return bundleDate{}, err
+		}
+	}
+
+	line, err := readFirstLine(bundle, systemDateFile)
+	if err != nil {
+		return bundleDate{}, err
+	}
+	matches := dateRegex.FindStringSubmatch(line)
+	if location == nil {
+		var zone string
+		if len(matches) != 0 {
+			zone = matches[2]
+		}
+		location = zoneLocation(zone)
+	}
+	if len(matches) == 0 {
+		return bundleDate{captured: time.Now().In(location)}, nil
+	}
+
+	captured, err := time.ParseInLocation(dateLayout, matches[1]+" "+matches[3], location)
+	if err != nil {
+		util.Log.Warnf("unable to parse %s, using the current time: %s", systemDateFile, err)
+		captured = time.Now().In(location)
+	}
+	return bundleDate{captured: captured}, nil
+}
+
+// readTimedatectl returns the timezone in the timedatectl output, or nil if
+// there isn't one that can be loaded.
+func readTimedatectl(bundle fs.FS) (*time.Location, error) {
+	data, err := fs.ReadFile(bundle, timedatectlFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	matches := timezoneRegex.FindSubmatch(data)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	location, err := time.LoadLocation(string(matches[1]))
+	if err != nil {
+		util.Log.Warnf("unknown timezone %s in %s: %s", matches[1], timedatectlFile, err)
+		return nil, nil
+	}
+	return location, nil
+}
+
+// zoneLocation returns the timezone for the zone printed by date.
+// Abbreviations other than UTC are ambiguous, CST could be China or Central
+// Standard Time, so UTC is used for them and a warning logged.
+func zoneLocation(zone string) *time.Location {
+	switch zone {
+	case "", "UTC", "GMT", "Z":
+		return time.UTC
+	}
+
+	if matches := offsetRegex.FindStringSubmatch(zone); matches != nil {
+		hours, _ := strconv.Atoi(matches[2])
+		var minutes int
+		if matches[3] != "" {
+			minutes, _ = strconv.Atoi(matches[3])
+		}
+		offset := hours*60*60 + minutes*60
+		if matches[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(zone, offset)
+	}
+
+	util.Log.Warnf("timezone %s of the bundle is ambiguous, logs without a timezone are taken to be in UTC, use --timezone to set it", zone)
+	return time.UTC
+}
+
+// readFirstLine returns the first line of the file, or an empty string if the
+// file is missing.
+func readFirstLine(bundle fs.FS, path string) (string, error) {
+	file, err := bundle.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan()
+	return scanner.Text(), scanner.Err()
+}
+
+// journaldHostname returns the hostname of the first journald log in the
+// bundle that has one, or an empty string if none do.
+func (s *shipper) journaldHostname(layout *Layout) string {
+	for _, componentLayout := range layout.Components {
+		if componentLayout.Parser != journaldParser {
+			continue
+		}
+		files, err := s.findFiles(componentLayout)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			hostname, err := input.FirstHostname(s.bundle, file.path, parsers[journaldParser](s.date))
+			if err != nil {
+				util.Log.Warnf("unable to read %s: %s", file.path, err)
+				continue
+			}
+			if hostname != "" {
+				return hostname
+			}
+		}
+	}
+	return ""
+}
+
+// findFiles returns the files in the bundle that match the component's paths,
+// and their rotations.
+func (s *shipper) findFiles(layout ComponentLayout) ([]logFile, error) {
+	if layout.Parser != journaldParser {
+		return findRotations(s.bundle, layout.Paths)
+	}
+	paths, err := structuredJournaldPaths(s.bundle, layout.Paths)
+	if err != nil {
+		return nil, err
+	}
+	return findRotations(s.bundle, paths)
+}
+
+// structuredJournaldPaths replaces each journald path with its journalctl -o
+// json or -o export output when the bundle has it, as these keep the journald
+// fields the text output loses.  Publishing both would index every entry
+// twice.
+func structuredJournaldPaths(bundle fs.FS, paths []string) ([]string, error) {
+	selected := make([]string, 0, len(paths))
+	for _, path := range paths {
+		structured := ""
+		for _, suffix := range []string{journaldJSONSuffix, journaldExportSuffix} {
+			matches, err := fs.Glob(bundle, path+suffix)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) > 0 {
+				structured = path + suffix
+				break
+			}
+		}
+		if structured == "" {
+			structured = path
+		}
+		selected = append(selected, structured)
+	}
+	return selected, nil
+}
+
+// componentFiles returns the files in the bundle that match the component's
+// paths, and their rotations, from oldest to newest.
+func (s *shipper) componentFiles(layout ComponentLayout) ([]logFile, error) {
+	files, err := s.findFiles(layout)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		if layout.Required {
+			return nil, errors.ErrMissingComponent(layout.Name)
+		}
+		util.Log.Infof("%s log is missing, skipping", layout.Name)
+		s.summary.Skipped = append(s.summary.Skipped, layout.Name)
+		return nil, nil
+	}
+
+	util.Log.Infof("publishing %s logs", layout.Name)
+	s.summary.Published = append(s.summary.Published, layout.Name)
+	return orderByFirstTimestamp(s.bundle, files, func() input.DateParser {
+		return parsers[layout.Parser](s.date)
+	}), nil
+}
+
+// filesSize returns the total size of the files.
+func (s *shipper) filesSize(files []logFile) (int64, error) {
+	var size int64
+	for _, file := range files {
+		info, err := fs.Stat(s.bundle, file.path)
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// run ships the jobs with a pool of workers.  The first error stops the
+// remaining jobs from starting, apart from files that couldn't be read to the
+// end, which are left unfinished while the other files are published.
+func (s *shipper) run(jobs []shipJob) error {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		readOnce sync.Once
+		readErr  error
+	)
+	finished := make([]bool, len(jobs))
+	queue := make(chan int)
+	for w := 0; w < s.config.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				err := s.shipFile(ctx, jobs[i])
+				if errors.IsUnreadableFile(err) {
+					util.Log.Errorf("%s", err)
+					readOnce.Do(func() {
+						readErr = err
+					})
+					continue
+				}
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				finished[i] = true
+			}
+		}()
+	}
+
+queueJobs:
+	for i := range jobs {
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+			break queueJobs
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	for i, job := range jobs {
+		if !finished[i] {
+			s.summary.Unfinished = append(s.summary.Unfinished, job.file.path)
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return readErr
+}
+
+func (s *shipper) shipFile(ctx context.Context, job shipJob) error {
+	component := input.NewFileInput(ctx, s.sink, input.FileConfig{
+		Bundle:         s.bundle,
+		ClusterID:      s.config.ClusterName,
+		NodeName:       s.config.NodeName,
+		Component:      job.layout.Component,
+		Paths:          []string{job.file.path},
+		Checkpoint:     s.config.Checkpoint,
+		Progress:       job.progress,
+		UnparsedPolicy: s.config.UnparsedPolicy,
+		MaxLineSize:    s.config.MaxLineSize,
+		Rotation:       job.file.rotation,
+	})
+
+	// Parsers may keep state between lines so each file gets its own
+	// parser.
+	start, end, err := component.Publish(parsers[job.layout.Parser](s.date), job.layout.LogType)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summary.Unparsed.Add(component.Unparsed())
+	s.summary.Truncated += component.Truncated()
+	// The logs before a read error were published
+	if err != nil && !errors.IsUnreadableFile(err) {
+		return err
+	}
+	if s.summary.Start.IsZero() || (!start.IsZero() && start.Before(s.summary.Start)) {
+		s.summary.Start = start
+	}
+	if s.summary.End.IsZero() || end.After(s.summary.End) {
+		s.summary.End = end
+	}
+	return err
+}
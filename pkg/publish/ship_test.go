@@ -0,0 +1,183 @@
+package publish
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/dbason/opni-supportagent/pkg/input"
+)
+
+// memorySink keeps the logs written to it.
+type memorySink struct {
+	mu   sync.Mutex
+	logs []input.LogMessage
+}
+
+func (s *memorySink) Write(_ context.Context, logs []input.LogMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logs = append(s.logs, logs...)
+	return nil
+}
+
+func (s *memorySink) Flush(context.Context) error { return nil }
+func (s *memorySink) Close(context.Context) error { return nil }
+
+func (s *memorySink) Stats() input.SinkStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return input.SinkStats{NumAdded: uint64(len(s.logs)), NumFlushed: uint64(len(s.logs))}
+}
+
+func TestReadBundleDate(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		location *time.Location
+		want     time.Time
+	}{
+		{
+			name: "UTC",
+			files: fstest.MapFS{
+				systemDateFile: {Data: []byte("Sun Jan  2 16:00:00 UTC 2022\n")},
+			},
+			want: time.Date(2022, time.January, 2, 16, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "numeric offset",
+			files: fstest.MapFS{
+				systemDateFile: {Data: []byte("Sun Jan  2 16:00:00 +0530 2022\n")},
+			},
+			want: time.Date(2022, time.January, 2, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "ambiguous abbreviation",
+			files: fstest.MapFS{
+				systemDateFile: {Data: []byte("Sun Jan  2 16:00:00 CST 2022\n")},
+			},
+			want: time.Date(2022, time.January, 2, 16, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "timedatectl",
+			files: fstest.MapFS{
+				systemDateFile:  {Data: []byte("Sun Jan  2 16:00:00 CST 2022\n")},
+				timedatectlFile: {Data: []byte("               Local time: Sun 2022-01-02 16:00:00 CST\n                Time zone: Asia/Shanghai (CST, +0800)\n")},
+			},
+			want: time.Date(2022, time.January, 2, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "timezone given",
+			files: fstest.MapFS{
+				systemDateFile:  {Data: []byte("Sun Jan  2 16:00:00 CST 2022\n")},
+				timedatectlFile: {Data: []byte("                Time zone: Asia/Shanghai (CST, +0800)\n")},
+			},
+			location: chicago,
+			want:     time.Date(2022, time.January, 2, 22, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, err := readBundleDate(tt.files, tt.location)
+			if err != nil {
+				t.Fatalf("readBundleDate error: %s", err)
+			}
+			if !date.captured.Equal(tt.want) {
+				t.Errorf("captured = %s, want %s", date.captured, tt.want)
+			}
+		})
+	}
+
+	date, err := readBundleDate(fstest.MapFS{
+		timedatectlFile: {Data: []byte("                Time zone: Asia/Shanghai (CST, +0800)\n")},
+	}, nil)
+	if err != nil {
+		t.Fatalf("readBundleDate without a date error: %s", err)
+	}
+	if date.captured.Location().String() != "Asia/Shanghai" {
+		t.Errorf("captured without a date is in %s, want Asia/Shanghai", date.captured.Location())
+	}
+}
+
+func TestZoneLocation(t *testing.T) {
+	tests := []struct {
+		zone   string
+		offset int
+	}{
+		{"", 0},
+		{"UTC", 0},
+		{"+0800", 8 * 60 * 60},
+		{"-0330", -(3*60*60 + 30*60)},
+		{"+05", 5 * 60 * 60},
+		{"IST", 0},
+	}
+	for _, tt := range tests {
+		_, offset := time.Date(2022, time.January, 2, 0, 0, 0, 0, zoneLocation(tt.zone)).Zone()
+		if offset != tt.offset {
+			t.Errorf("zoneLocation(%q) offset = %d, want %d", tt.zone, offset, tt.offset)
+		}
+	}
+}
+
+func TestShipControlPlaneStructuredJournald(t *testing.T) {
+	layout, err := LoadLayout(K3S, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "Jan 02 15:04:05 node1 k3s[856]: Starting k3s\n" +
+		"Jan 02 15:04:06 node1 k3s[856]: Started k3s\n"
+	json := `{"__REALTIME_TIMESTAMP":"1641135845000000","_PID":"856","SYSLOG_IDENTIFIER":"k3s","_HOSTNAME":"node1","MESSAGE":"Starting k3s"}` + "\n" +
+		`{"__REALTIME_TIMESTAMP":"1641135846000000","_PID":"856","SYSLOG_IDENTIFIER":"k3s","_HOSTNAME":"node1","MESSAGE":"Started k3s"}` + "\n"
+
+	for _, tt := range []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{
+			name: "text only",
+			files: fstest.MapFS{
+				"journald/k3s": {Data: []byte(text)},
+			},
+			want: "journald/k3s",
+		},
+		{
+			name: "text and JSON",
+			files: fstest.MapFS{
+				"journald/k3s":      {Data: []byte(text)},
+				"journald/k3s.json": {Data: []byte(json)},
+			},
+			want: "journald/k3s.json",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files[systemDateFile] = &fstest.MapFile{Data: []byte("Sun Jan  2 16:00:00 UTC 2022\n")}
+			sink := &memorySink{}
+			_, err := ShipControlPlane(context.Background(), tt.files, layout, sink, ShipConfig{
+				ClusterName: "case",
+				NodeName:    "node1",
+			})
+			if err != nil {
+				t.Fatalf("ShipControlPlane error: %s", err)
+			}
+			if len(sink.logs) != 2 {
+				t.Fatalf("published %d logs, want 2", len(sink.logs))
+			}
+			for _, log := range sink.logs {
+				if log.BundleFile != tt.want {
+					t.Errorf("log %q read from %s, want %s", log.Log, log.BundleFile, tt.want)
+				}
+			}
+		})
+	}
+}
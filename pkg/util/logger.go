@@ -2,6 +2,9 @@ package util
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/ttacon/chalk"
@@ -14,6 +17,38 @@ import (
 var Log *zap.SugaredLogger
 var startTime = atomic.NewInt64(time.Now().Unix())
 
+// logOutput is where Log writes, stdout unless it is replaced with
+// SetLogOutput.
+var logOutput = &switchableWriter{w: os.Stdout}
+
+type switchableWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchableWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.w.Write(p)
+}
+
+// SetLogOutput makes Log write to w until the returned function is called,
+// which restores the output it had before.
+func SetLogOutput(w io.Writer) func() {
+	logOutput.mu.Lock()
+	defer logOutput.mu.Unlock()
+
+	previous := logOutput.w
+	logOutput.w = w
+	return func() {
+		logOutput.mu.Lock()
+		defer logOutput.mu.Unlock()
+
+		logOutput.w = previous
+	}
+}
+
 func init() {
 	encoderCfg := zapcore.EncoderConfig{
 		MessageKey:       "M",
@@ -33,20 +68,14 @@ func init() {
 		},
 		EncodeDuration: zapcore.SecondsDurationEncoder,
 	}
-	cfg := zap.Config{
-		Level:             zap.NewAtomicLevelAt(zap.InfoLevel),
-		Development:       false,
-		DisableCaller:     false,
-		DisableStacktrace: true,
-		Sampling:          nil,
-		Encoding:          "console",
-		EncoderConfig:     encoderCfg,
-		OutputPaths:       []string{"stdout"},
-		ErrorOutputPaths:  []string{"stderr"},
-	}
-	logger, err := cfg.Build()
-	if err != nil {
-		panic(err)
-	}
+	core := zapcore.NewCore(
+		zapcore.NewConsoleEncoder(encoderCfg),
+		zapcore.AddSync(logOutput),
+		zap.NewAtomicLevelAt(zap.InfoLevel),
+	)
+	logger := zap.New(core,
+		zap.AddCaller(),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	)
 	Log = logger.Sugar()
 }